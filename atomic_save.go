@@ -0,0 +1,81 @@
+package dirnotify
+
+import (
+	"path/filepath"
+	"strings"
+	// third party
+	"github.com/satom9to5/fileinfo"
+)
+
+// temp file names of atomic save. {name} is target file name.
+var atomicSavePatterns = []string{
+	"{name}.tmp",
+	"{name}.tmp.*",
+	"{name}~",
+	".{name}.swp",
+	".{name}.??????", // mkstemp suffix (e.g. rsync)
+	".goutputstream-*",
+}
+
+// SetAtomicSave enables recognizing save by renaming temp file over target.
+// it is sent as Write of target instead of Create and Replace.
+// events of temp files removed before processing are ignored only when enabled.
+func (r *Root) SetAtomicSave(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.atomicSave = enabled
+}
+
+func isAtomicSaveTemp(tmp, name string) bool {
+	escaped := strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]", `\`, `\\`).Replace(name)
+
+	for _, pattern := range atomicSavePatterns {
+		if ok, _ := filepath.Match(strings.Replace(pattern, "{name}", escaped, -1), tmp); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// find Rename queue of temp file which is not in node tree.
+// temp file is created and renamed to p in same queues.
+func (eqs *eventQueues) findTempRename(p string) string {
+	dir, name := fileinfo.Split(p)
+
+	for _, eq := range *eqs {
+		if eq.Op&Rename == Rename && eq.node == nil && eq.dir == dir && isAtomicSaveTemp(eq.base, name) {
+			return eq.Path()
+		}
+	}
+
+	return ""
+}
+
+// Replace by temp file on same directory is rewritten to Write.
+func (nes *nodeEvents) atomicSave() {
+	for i, ne := range *nes {
+		if ne.Op != Replace || ne.beforePath == "" || ne.node == nil || ne.node.IsDir() {
+			continue
+		}
+
+		dir, name := fileinfo.Split(ne.node.Path())
+		tmpDir, tmp := fileinfo.Split(ne.beforePath)
+
+		if dir != tmpDir || !isAtomicSaveTemp(tmp, name) {
+			continue
+		}
+
+		ne.Op = Write
+		ne.beforePath = ""
+		// state of overwritten target
+		ne.prev = ne.replacedPrev
+		// target keeps correlation ID
+		if ne.replacedID != 0 {
+			ne.node.id = ne.replacedID
+		}
+
+		(*nes)[i] = ne
+	}
+}
@@ -0,0 +1,144 @@
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	// third party
+	"github.com/satom9to5/fsnotify"
+)
+
+func TestIsAtomicSaveTemp(t *testing.T) {
+	patterns := []struct {
+		tmp    string
+		name   string
+		expect bool
+	}{
+		{"app.cfg.tmp", "app.cfg", true},
+		{"app.cfg.tmp.1234", "app.cfg", true},
+		{"app.cfg~", "app.cfg", true},
+		{".app.cfg.swp", "app.cfg", true},
+		{".app.cfg.Xa3bZ9", "app.cfg", true},
+		{".app.cfg.old", "app.cfg", false},
+		{".app.cfg.Xa3bZ9.bak", "app.cfg", false},
+		{".goutputstream-Q2W9TZ", "app.cfg", true},
+		{"new.cfg", "app.cfg", false},
+		{"app.cfg", "app.cfg", false},
+		{"a[1].tmp", "a[1]", true},
+		{"ab.tmp", "a*", false},
+	}
+
+	for _, pattern := range patterns {
+		if ok := isAtomicSaveTemp(pattern.tmp, pattern.name); ok != pattern.expect {
+			t.Fatalf("[TestIsAtomicSaveTemp] result is different. tmp: %s, name: %s, expect: %t", pattern.tmp, pattern.name, pattern.expect)
+		}
+	}
+}
+
+func TestAtomicSave(t *testing.T) {
+	patterns := []struct {
+		atomicSave bool
+		tmpInTree  bool
+		Op
+	}{
+		{false, true, Replace},
+		{true, false, Write},
+		{true, true, Write},
+	}
+
+	for _, pattern := range patterns {
+		dir := tempdir()
+		defer os.RemoveAll(dir)
+
+		p := filepath.Join(dir, "app.cfg")
+		tmp := filepath.Join(dir, ".app.cfg.swp")
+
+		if err := ioutil.WriteFile(p, make([]byte, 10), 0644); err != nil {
+			t.Fatalf("[TestAtomicSave] failed to write file: %s", err)
+		}
+		if pattern.tmpInTree {
+			if err := ioutil.WriteFile(tmp, make([]byte, 20), 0644); err != nil {
+				t.Fatalf("[TestAtomicSave] failed to write file: %s", err)
+			}
+		}
+
+		r, err := CreateNodeTree([]string{dir})
+		if err != nil {
+			t.Fatalf("[TestAtomicSave] cannot create Root: %s", err)
+		}
+		defer r.Close()
+
+		r.SetAtomicSave(pattern.atomicSave)
+
+		target, err := r.Find(p)
+		if err != nil {
+			t.Fatalf("[TestAtomicSave] failed to Root/Find: %s", err)
+		}
+		id := target.CorrelationID()
+
+		eqs := &eventQueues{}
+		if !pattern.tmpInTree {
+			if err := ioutil.WriteFile(tmp, make([]byte, 20), 0644); err != nil {
+				t.Fatalf("[TestAtomicSave] failed to write file: %s", err)
+			}
+			eqs.add(fsnotify.Event{Name: tmp, Op: fsnotify.Create}, r)
+			eqs.add(fsnotify.Event{Name: tmp, Op: fsnotify.Write}, r)
+		}
+
+		if err := os.Rename(tmp, p); err != nil {
+			t.Fatalf("[TestAtomicSave] failed to rename: %s", err)
+		}
+
+		eqs.add(fsnotify.Event{Name: tmp, Op: fsnotify.Rename}, r)
+		eqs.add(fsnotify.Event{Name: p, Op: fsnotify.Create}, r)
+		eqs.sort()
+
+		nes, err := eqs.createNodeEvents(r)
+		if err != nil {
+			t.Fatalf("[TestAtomicSave] failed to createNodeEvents: %s", err)
+		}
+
+		if len(*nes) != 1 {
+			t.Fatalf("[TestAtomicSave] event length is different. expect: 1, fact: %d", len(*nes))
+		}
+
+		e := newEvent((*nes)[0])
+		if e.Op() != pattern.Op || e.Path() != p || e.Size() != 20 {
+			t.Fatalf("[TestAtomicSave] event is different: %s", e)
+		}
+
+		if pattern.Op == Write && (e.BeforePath() != "" || e.PrevSize() != 10 || e.CorrelationID() != id) {
+			t.Fatalf("[TestAtomicSave] Write event is different: %s, prev size: %d", e, e.PrevSize())
+		}
+	}
+}
+
+func TestAtomicSaveDisabled(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestAtomicSaveDisabled] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	// created and removed before queues are processed.
+	tmp := filepath.Join(dir, ".app.cfg.swp")
+
+	for _, atomicSave := range []bool{false, true} {
+		r.SetAtomicSave(atomicSave)
+
+		eqs := &eventQueues{}
+		eqs.add(fsnotify.Event{Name: tmp, Op: fsnotify.Create}, r)
+
+		nes, err := eqs.createNodeEvents(r)
+		if !atomicSave && err == nil {
+			t.Fatalf("[TestAtomicSaveDisabled] nonexistent path is ignored without atomic save.")
+		}
+		if atomicSave && (err != nil || len(*nes) != 0) {
+			t.Fatalf("[TestAtomicSaveDisabled] nonexistent path is not ignored with atomic save: %v", err)
+		}
+	}
+}
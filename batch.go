@@ -0,0 +1,133 @@
+package dirnotify
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// events sent since previous tick.
+// events sent between ticks (close write, debounce and checksum) are in next Batch.
+type Batch struct {
+	ID     uint64    // sequence number per Root
+	Start  time.Time // first observed time of events
+	End    time.Time // tick time batch is sent
+	Events []Event
+}
+
+// sent events waiting for next tick.
+type batchEvents struct {
+	events []Event
+	mu     sync.Mutex
+}
+
+// filter by subscriber Op mask.
+func (s *subscriber) filterBatch(b Batch) Batch {
+	if s.opts.Op == 0 {
+		return b
+	}
+
+	events := []Event{}
+	for _, e := range b.Events {
+		if s.match(e) {
+			events = append(events, e)
+		}
+	}
+	b.Events = events
+
+	return b
+}
+
+func (s *subscriber) deliverBatch(b Batch) {
+	if s.batches == nil {
+		return
+	}
+
+	b = s.filterBatch(b)
+	if len(b.Events) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.overflow(func() bool {
+		select {
+		case s.batches <- b:
+			return true
+		default:
+			return false
+		}
+	}, func() bool {
+		select {
+		case <-s.batches:
+			return true
+		default:
+			return false
+		}
+	}, func() {
+		select {
+		case s.batches <- b:
+		case <-s.done:
+		case <-s.closing:
+		}
+	})
+}
+
+// SubscribeBatches returns event stream coalesced per tick. (Watch is required)
+// events in Batch are also sent to Subscribe channels and handlers.
+// Root.Ch is not sent while batch subscriber exists, same as Subscribe.
+func (r *Root) SubscribeBatches(opts SubscribeOptions) (<-chan Batch, func()) {
+	s := newSubscriber(opts)
+	s.batches = make(chan Batch, s.opts.BufferSize)
+
+	return s.batches, r.subscribe(s)
+}
+
+// hold sent events until next tick.
+func (r *Root) addBatch(events []Event) {
+	r.batch.mu.Lock()
+	defer r.batch.mu.Unlock()
+
+	r.batch.events = append(r.batch.events, events...)
+}
+
+// send events since previous tick as one Batch.
+// called on tick of watch loop.
+func (r *Root) sendBatch() {
+	r.batch.mu.Lock()
+	events := r.batch.events
+	r.batch.events = nil
+	r.batch.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	// events of checksum workers may be added after later events.
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].seq < events[j].seq
+	})
+
+	b := Batch{
+		ID:     atomic.AddUint64(&r.batchSeq, 1),
+		Start:  events[0].observedAt,
+		End:    time.Now(),
+		Events: events,
+	}
+
+	for _, e := range events {
+		if e.observedAt.Before(b.Start) {
+			b.Start = e.observedAt
+		}
+	}
+
+	for _, s := range r.subscribers.snapshot() {
+		s.deliverBatch(b)
+	}
+}
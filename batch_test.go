@@ -0,0 +1,86 @@
+package dirnotify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeBatches(t *testing.T) {
+	r := newTestEventRoot()
+
+	allCh, cancelAll := r.SubscribeBatches(SubscribeOptions{BufferSize: 2})
+	defer cancelAll()
+	removeCh, cancelRemove := r.SubscribeBatches(SubscribeOptions{Op: Remove, BufferSize: 2})
+	defer cancelRemove()
+
+	observedAt := time.Now().Add(-1 * time.Second)
+
+	events := []Event{}
+	for _, e := range []Event{
+		Event{op: Create, path: "/tmp/foo", observedAt: observedAt.Add(time.Millisecond)},
+		Event{op: Create, path: "/tmp/bar", observedAt: observedAt},
+	} {
+		events = append(events, r.send(e))
+	}
+
+	// one Batch per tick
+	r.addBatch(events[:1])
+	r.addBatch(events[1:])
+	r.sendBatch()
+	r.addBatch([]Event{r.send(Event{op: Remove, path: "/tmp/foo"})})
+	r.sendBatch()
+
+	// nothing sent since previous tick
+	r.sendBatch()
+
+	b := <-allCh
+	if b.ID != 1 || len(b.Events) != 2 || !b.Start.Equal(observedAt) || b.End.Before(b.Start) {
+		t.Fatalf("[TestSubscribeBatches] batch is different: %+v", b)
+	}
+	if b.Events[0].Seq() != 1 || b.Events[1].Seq() != 2 {
+		t.Fatalf("[TestSubscribeBatches] sequence is different: %d, %d", b.Events[0].Seq(), b.Events[1].Seq())
+	}
+
+	if b = <-allCh; b.ID != 2 || len(b.Events) != 1 {
+		t.Fatalf("[TestSubscribeBatches] batch is different: %+v", b)
+	}
+
+	// filtered
+	if b = <-removeCh; b.ID != 2 || len(b.Events) != 1 || b.Events[0].Op() != Remove {
+		t.Fatalf("[TestSubscribeBatches] filtered batch is different: %+v", b)
+	}
+	if len(removeCh) != 0 || len(allCh) != 0 {
+		t.Fatalf("[TestSubscribeBatches] empty batch is sent.")
+	}
+
+	// added by checksum worker after later event.
+	hashed := r.send(Event{op: WriteComplete, path: "/tmp/bar"})
+	r.addBatch([]Event{r.send(Event{op: Remove, path: "/tmp/bar"})})
+	r.addBatch([]Event{hashed})
+	r.sendBatch()
+
+	if b = <-allCh; len(b.Events) != 2 || b.Events[0].Op() != WriteComplete || b.Events[0].Seq() > b.Events[1].Seq() {
+		t.Fatalf("[TestSubscribeBatches] batch is not ordered by sequence: %+v", b)
+	}
+}
+
+func TestSubscribeBatchesOverflow(t *testing.T) {
+	r := newTestEventRoot()
+
+	ch, cancel := r.SubscribeBatches(SubscribeOptions{BufferSize: 1, Overflow: DropOldest})
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		r.addBatch([]Event{r.send(Event{op: Create, path: "/tmp/foo"})})
+		r.sendBatch()
+	}
+
+	if b := <-ch; b.ID != 2 {
+		t.Fatalf("[TestSubscribeBatchesOverflow] oldest batch is not dropped: %+v", b)
+	}
+
+	s := r.subscribers.snapshot()[0]
+	if s.dropped != 1 || s.ch != nil {
+		t.Fatalf("[TestSubscribeBatchesOverflow] subscriber is different. dropped: %d", s.dropped)
+	}
+}
@@ -0,0 +1,305 @@
+package dirnotify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	// third party
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	defaultChecksumWorkers = 1
+	checksumQueueSize      = 64
+)
+
+type HashAlgorithm int
+
+const (
+	HashNone HashAlgorithm = iota
+	HashSHA256
+	HashXXHash
+)
+
+var (
+	hashNames = map[HashAlgorithm]string{
+		HashNone:   "",
+		HashSHA256: "sha256",
+		HashXXHash: "xxhash",
+	}
+)
+
+// content hash of WriteComplete files on worker goroutines.
+type checksums struct {
+	algo    HashAlgorithm
+	maxSize int64 // 0 is no limit
+	workers int
+	queue   chan Event
+	pending map[string][]Event // held events of path waiting hash
+	done    chan struct{}
+	started bool
+	stopped bool
+	mu      sync.Mutex
+}
+
+func (h HashAlgorithm) String() string {
+	return hashNames[h]
+}
+
+func (h HashAlgorithm) MarshalText() ([]byte, error) {
+	name, ok := hashNames[h]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("[HashAlgorithm/MarshalText] error: unknown algorithm %d.", h))
+	}
+
+	return []byte(name), nil
+}
+
+func (h *HashAlgorithm) UnmarshalText(text []byte) error {
+	for algo, name := range hashNames {
+		if strings.EqualFold(name, string(text)) {
+			*h = algo
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("[HashAlgorithm/UnmarshalText] error: unknown algorithm %s.", text))
+}
+
+func (h HashAlgorithm) new() hash.Hash {
+	switch h {
+	case HashSHA256:
+		return sha256.New()
+	case HashXXHash:
+		return xxhash.New()
+	}
+
+	return nil
+}
+
+func newChecksums() *checksums {
+	return &checksums{
+		workers: defaultChecksumWorkers,
+		queue:   make(chan Event, checksumQueueSize),
+		pending: map[string][]Event{},
+		done:    make(chan struct{}),
+	}
+}
+
+// SetChecksum computes content hash of file on WriteComplete.
+// files larger than maxSize are sent without hash. (0 is no limit)
+// later events of same path are held until hash is computed.
+// hash is skipped when queue is full. (Status.ChecksumDrops)
+// HashNone is disabled.
+func (r *Root) SetChecksum(algo HashAlgorithm, maxSize int64) error {
+	if _, ok := hashNames[algo]; !ok {
+		return errors.New("[Root/SetChecksum] error: unknown algorithm.")
+	}
+	if maxSize < 0 {
+		return errors.New("[Root/SetChecksum] error: maxSize must be 0 or more.")
+	}
+
+	r.checksums.mu.Lock()
+	defer r.checksums.mu.Unlock()
+
+	r.checksums.algo = algo
+	r.checksums.maxSize = maxSize
+
+	return nil
+}
+
+// SetChecksumWorkers sets number of goroutines computing hash.
+func (r *Root) SetChecksumWorkers(n int) error {
+	return r.checksums.setWorkers(n)
+}
+
+func (cs *checksums) setWorkers(n int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if n < 1 {
+		return errors.New("[checksums/setWorkers] error: workers must be 1 or more.")
+	}
+	if cs.started {
+		return errors.New("[checksums/setWorkers] error: workers already started.")
+	}
+
+	cs.workers = n
+
+	return nil
+}
+
+// queue event for hash without blocking.
+// false when event should be sent without hash.
+func (cs *checksums) submit(e Event, r *Root) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.algo == HashNone || cs.stopped || (cs.maxSize > 0 && e.size > cs.maxSize) {
+		return false
+	}
+	if !cs.started {
+		cs.started = true
+
+		for i := 0; i < cs.workers; i++ {
+			go cs.work(r)
+		}
+	}
+
+	e.checksumAlgorithm = cs.algo
+
+	// hashed after former WriteComplete of same path.
+	if held, ok := cs.pending[e.path]; ok {
+		cs.pending[e.path] = append(held, e)
+		return true
+	}
+
+	if !cs.enqueue(e, r) {
+		return false
+	}
+	cs.pending[e.path] = []Event{}
+
+	return true
+}
+
+// called when checksums.mu locked.
+func (cs *checksums) enqueue(e Event, r *Root) bool {
+	select {
+	case cs.queue <- e:
+		return true
+	default:
+		if debug {
+			log.Println("[checksums/enqueue] queue is full: " + e.path)
+		}
+		r.status.update(func(st *Status) {
+			st.ChecksumDrops++
+		})
+		return false
+	}
+}
+
+// hold event of path waiting hash. (called on Root.emit)
+func (cs *checksums) hold(e Event) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, p := range []string{e.path, e.beforePath} {
+		if held, ok := cs.pending[p]; ok && p != "" {
+			cs.pending[p] = append(held, e)
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashed event and held events of same path.
+// held events after next WriteComplete waiting hash are kept.
+func (cs *checksums) release(e Event, r *Root) []Event {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	events := []Event{e}
+	held := cs.pending[e.path]
+	delete(cs.pending, e.path)
+
+	for i, h := range held {
+		if h.op == WriteComplete && h.checksumAlgorithm != HashNone {
+			if cs.enqueue(h, r) {
+				cs.pending[e.path] = held[i+1:]
+				return events
+			}
+			h.checksumAlgorithm = HashNone
+		}
+
+		events = append(events, h)
+	}
+
+	return events
+}
+
+func (cs *checksums) work(r *Root) {
+	for {
+		select {
+		case e := <-cs.queue:
+			sum, err := checksum(e.path, e.size, e.checksumAlgorithm)
+			if err != nil {
+				if debug {
+					log.Println(err)
+				}
+				e.checksumAlgorithm = HashNone
+			} else {
+				e.checksum = sum
+			}
+
+			// released and sent in one Root.mu section,
+			// so later events of path emitted by watch loop are held until sent.
+			r.mu.Lock()
+			r.sendHashed(cs.release(e, r))
+			r.mu.Unlock()
+		case <-cs.done:
+			return
+		}
+	}
+}
+
+// called when Root.mu locked.
+func (r *Root) sendHashed(events []Event) {
+	if r.debouncer != nil {
+		for _, e := range events {
+			r.debouncer.add(e)
+		}
+		return
+	}
+
+	sent := []Event{}
+	for _, e := range events {
+		sent = append(sent, r.send(e))
+	}
+
+	r.addBatch(sent)
+}
+
+func (cs *checksums) stop() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.stopped {
+		return
+	}
+
+	cs.stopped = true
+	close(cs.done)
+}
+
+// hex encoded hash of p.
+// error when size is changed after WriteComplete.
+func checksum(p string, size int64, algo HashAlgorithm) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := algo.new()
+	if h == nil {
+		return "", errors.New("[checksum] error: unknown algorithm.")
+	}
+
+	n, err := io.Copy(h, io.LimitReader(f, size+1))
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", errors.New(fmt.Sprintf("[checksum] error: %s size is changed.", p))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
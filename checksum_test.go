@@ -0,0 +1,204 @@
+package dirnotify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	// third party
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestChecksum(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "test.txt")
+	if err := ioutil.WriteFile(p, []byte("test"), 0644); err != nil {
+		t.Fatalf("[TestChecksum] failed to write file: %s", err)
+	}
+
+	patterns := []struct {
+		algo   HashAlgorithm
+		expect string
+	}{
+		{HashSHA256, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{HashXXHash, fmt.Sprintf("%016x", xxhash.Sum64String("test"))},
+	}
+
+	for _, pattern := range patterns {
+		if sum, err := checksum(p, 4, pattern.algo); err != nil || sum != pattern.expect {
+			t.Fatalf("[TestChecksum] %s is different. expect: %s, fact: %s, error: %v", pattern.algo, pattern.expect, sum, err)
+		}
+
+		var algo HashAlgorithm
+		if err := algo.UnmarshalText([]byte(pattern.algo.String())); err != nil || algo != pattern.algo {
+			t.Fatalf("[TestChecksum] failed to UnmarshalText: %s", pattern.algo)
+		}
+	}
+
+	if _, err := checksum(p, 3, HashSHA256); err == nil {
+		t.Fatalf("[TestChecksum] changed size is accepted.")
+	}
+
+	r := newTestEventRoot()
+	defer r.checksums.stop()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 1})
+	defer cancel()
+
+	if err := r.SetChecksum(HashSHA256, 4); err != nil {
+		t.Fatalf("[TestChecksum] failed to SetChecksum: %s", err)
+	}
+	if err := r.SetChecksum(HashAlgorithm(-1), 0); err == nil {
+		t.Fatalf("[TestChecksum] unknown algorithm is accepted.")
+	}
+
+	// larger than limit
+	if r.checksums.submit(Event{op: WriteComplete, path: p, size: 5}, r) {
+		t.Fatalf("[TestChecksum] larger file is queued.")
+	}
+
+	if !r.checksums.submit(Event{op: WriteComplete, path: p, size: 4}, r) {
+		t.Fatalf("[TestChecksum] event is not queued.")
+	}
+
+	select {
+	case e := <-ch:
+		if e.Checksum() != patterns[0].expect || e.ChecksumAlgorithm() != HashSHA256 {
+			t.Fatalf("[TestChecksum] event checksum is different: %s %s", e.ChecksumAlgorithm(), e.Checksum())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestChecksum] too long to wait for event.")
+	}
+
+	if err := r.SetChecksumWorkers(2); err == nil {
+		t.Fatalf("[TestChecksum] workers are changed after started.")
+	}
+}
+
+func TestChecksumOrder(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "test.txt")
+	if err := ioutil.WriteFile(p, []byte("test"), 0644); err != nil {
+		t.Fatalf("[TestChecksumOrder] failed to write file: %s", err)
+	}
+
+	r := newTestEventRoot()
+	defer r.checksums.stop()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	if err := r.SetChecksum(HashSHA256, 0); err != nil {
+		t.Fatalf("[TestChecksumOrder] failed to SetChecksum: %s", err)
+	}
+
+	// workers are started after events are held.
+	r.checksums.started = true
+
+	for i := 0; i < 2; i++ {
+		if !r.checksums.submit(Event{op: WriteComplete, path: p, size: 4}, r) {
+			t.Fatalf("[TestChecksumOrder] event is not queued.")
+		}
+	}
+
+	r.mu.Lock()
+	events := r.emit(nil, Event{op: Remove, path: p})
+	r.mu.Unlock()
+
+	if len(events) != 0 || len(ch) != 0 {
+		t.Fatalf("[TestChecksumOrder] event is sent before hash.")
+	}
+
+	go r.checksums.work(r)
+
+	for _, op := range []Op{WriteComplete, WriteComplete, Remove} {
+		select {
+		case e := <-ch:
+			if e.Op() != op || (op == WriteComplete && e.Checksum() == "") {
+				t.Fatalf("[TestChecksumOrder] event is different. expect: %s, fact: %s", op, e)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("[TestChecksumOrder] too long to wait for %s.", op)
+		}
+	}
+
+	// queue is full without workers.
+	cs := newChecksums()
+	cs.algo = HashSHA256
+	cs.started = true
+	r.checksums = cs
+
+	for i := 0; i < checksumQueueSize; i++ {
+		if !cs.submit(Event{op: WriteComplete, path: fmt.Sprintf("%s.%d", p, i), size: 4}, r) {
+			t.Fatalf("[TestChecksumOrder] event is not queued.")
+		}
+	}
+
+	if cs.submit(Event{op: WriteComplete, path: p, size: 4}, r) {
+		t.Fatalf("[TestChecksumOrder] event is queued on full queue.")
+	}
+	if st := r.status.snapshot(); st.ChecksumDrops != 1 {
+		t.Fatalf("[TestChecksumOrder] dropped count is different. expect: 1, fact: %d", st.ChecksumDrops)
+	}
+}
+
+func TestChecksumRelease(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "test.txt")
+	if err := ioutil.WriteFile(p, []byte("test"), 0644); err != nil {
+		t.Fatalf("[TestChecksumRelease] failed to write file: %s", err)
+	}
+
+	r := newTestEventRoot()
+	defer r.checksums.stop()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	if err := r.SetChecksum(HashSHA256, 0); err != nil {
+		t.Fatalf("[TestChecksumRelease] failed to SetChecksum: %s", err)
+	}
+
+	r.checksums.started = true
+
+	if !r.checksums.submit(Event{op: WriteComplete, path: p, size: 4}, r) {
+		t.Fatalf("[TestChecksumRelease] event is not queued.")
+	}
+
+	// hash is computed while watch loop holds Root.mu.
+	r.mu.Lock()
+	go r.checksums.work(r)
+
+	for len(r.checksums.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	events := r.emit(nil, Event{op: Move, path: p + ".bak", beforePath: p})
+	r.mu.Unlock()
+
+	if len(events) != 0 {
+		t.Fatalf("[TestChecksumRelease] Move is sent before hashed WriteComplete.")
+	}
+
+	var seq uint64
+	for _, op := range []Op{WriteComplete, Move} {
+		select {
+		case e := <-ch:
+			if e.Op() != op || e.Seq() <= seq {
+				t.Fatalf("[TestChecksumRelease] event is different. expect: %s, fact: %s (seq: %d)", op, e, e.Seq())
+			}
+			seq = e.Seq()
+		case <-time.After(3 * time.Second):
+			t.Fatalf("[TestChecksumRelease] too long to wait for %s.", op)
+		}
+	}
+}
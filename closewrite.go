@@ -0,0 +1,128 @@
+package dirnotify
+
+import (
+	"time"
+)
+
+const (
+	closedBufferSize = 64
+	closeExpire      = 2 * time.Second // wait for write event of closed file
+)
+
+// SetCloseWrite enables WriteComplete on closing file after writing. (Linux only)
+// polling is kept for files opened before watching.
+func (r *Root) SetCloseWrite(enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !enabled {
+		if r.closeWatcher != nil {
+			r.closeWatcher.close()
+			r.closeWatcher = nil
+		}
+
+		return nil
+	}
+
+	if r.closeWatcher != nil {
+		return nil
+	}
+
+	w, err := newCloseWatcher(r.closed)
+	if err != nil {
+		return err
+	}
+
+	// watching directories
+	for _, node := range *(r.nodeMap) {
+		if node.IsDir() {
+			if err := w.add(node.Path()); err != nil {
+				w.close()
+				return err
+			}
+		}
+	}
+
+	r.closeWatcher = w
+
+	return nil
+}
+
+// called on watch loop.
+// closed file is completed on next tick, using opened files scanned once on the tick.
+func (r *Root) addClosed(p string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closes[p] = time.Now()
+}
+
+// send WriteComplete of closed files.
+func (r *Root) checkClosed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.closes) == 0 {
+		return
+	}
+
+	defer r.updateStatus()
+
+	events := []Event{}
+
+	for p, closedAt := range r.closes {
+		node, err := r.Find(p)
+		if err == nil {
+			node = r.writeNodes.get(node.key())
+		}
+
+		if node == nil {
+			// expired when write event is not found.
+			if time.Since(closedAt) > closeExpire {
+				delete(r.closes, p)
+			}
+			continue
+		}
+
+		delete(r.closes, p)
+
+		preTime := node.ModTime()
+		preSize := node.Size()
+		if err := node.Stat(); err != nil {
+			// removed after close
+			r.writeNodes.remove(node.key())
+			node.endWrite()
+			continue
+		}
+		node.writeClosed = true
+
+		// strategy, other writer or partial name
+		changed := node.ModTime() != preTime || node.Size() != preSize
+		if !r.isComplete(node, node.writeState(changed)) {
+			continue
+		}
+
+		r.writeNodes.remove(node.key())
+		node.closed = node.state()
+
+		events = r.completeWrite(events, node)
+	}
+
+	r.addBatch(events)
+}
+
+// check write event is not older than WriteComplete on close.
+func (n *Node) writtenAfterClose() bool {
+	if n.closed == nil {
+		return true
+	}
+
+	n.Stat()
+	if n.Size() == n.closed.size && n.ModTime().Equal(n.closed.modTime) {
+		return false
+	}
+
+	n.closed = nil
+
+	return true
+}
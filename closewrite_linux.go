@@ -0,0 +1,136 @@
+//go:build linux
+// +build linux
+
+package dirnotify
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotify watcher only for IN_CLOSE_WRITE.
+// fsnotify does not report closing file.
+type closeWatcher struct {
+	fd   int
+	f    *os.File // read on runtime poller
+	mu   sync.Mutex
+	dirs map[int]string // watch descriptor key
+	wds  map[string]int
+	ch   chan<- string // closed file path
+	done chan struct{}
+	once sync.Once // for close
+}
+
+func newCloseWatcher(ch chan<- string) (*closeWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &closeWatcher{
+		fd:   fd,
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		dirs: map[int]string{},
+		wds:  map[string]int{},
+		ch:   ch,
+		done: make(chan struct{}),
+	}
+
+	go w.read()
+
+	return w, nil
+}
+
+func (w *closeWatcher) add(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_ONLYDIR)
+	if err != nil {
+		return err
+	}
+
+	w.dirs[wd] = dir
+	w.wds[dir] = wd
+
+	return nil
+}
+
+func (w *closeWatcher) remove(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wd, ok := w.wds[dir]
+	if !ok {
+		return nil
+	}
+
+	delete(w.wds, dir)
+	delete(w.dirs, wd)
+
+	// watch is removed by kernel when not exist.
+	syscall.InotifyRmWatch(w.fd, uint32(wd))
+
+	return nil
+}
+
+// called more than once on Close and rebuild.
+func (w *closeWatcher) close() (err error) {
+	w.once.Do(func() {
+		close(w.done)
+		err = w.f.Close()
+	})
+
+	return err
+}
+
+func (w *closeWatcher) read() {
+	buf := make([]byte, (syscall.SizeofInotifyEvent+syscall.NAME_MAX+1)*16)
+
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			// closed
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(ev.Len)
+
+			if ev.Mask&syscall.IN_IGNORED == syscall.IN_IGNORED {
+				w.mu.Lock()
+				if dir, ok := w.dirs[int(ev.Wd)]; ok {
+					delete(w.dirs, int(ev.Wd))
+					delete(w.wds, dir)
+				}
+				w.mu.Unlock()
+				continue
+			}
+
+			if ev.Mask&syscall.IN_CLOSE_WRITE == 0 || ev.Len == 0 {
+				continue
+			}
+
+			w.mu.Lock()
+			dir, ok := w.dirs[int(ev.Wd)]
+			w.mu.Unlock()
+
+			if !ok {
+				continue
+			}
+
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			select {
+			case w.ch <- dir + string(os.PathSeparator) + name:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	// third party
+	"github.com/satom9to5/fsnotify"
+)
+
+func TestCloseWrite(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	if err := r.SetCloseWrite(true); err != nil {
+		t.Fatalf("[TestCloseWrite] failed to SetCloseWrite: %s", err)
+	}
+
+	ch, cancel := r.Subscribe(SubscribeOptions{Op: WriteComplete, BufferSize: 1})
+	defer cancel()
+
+	r.Watch()
+
+	p := filepath.Join(dir, "upload", "data.bin")
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("[TestCloseWrite] failed to create file: %s", err)
+	}
+	if _, err = f.Write(make([]byte, 1024)); err != nil {
+		t.Fatalf("[TestCloseWrite] failed to write file: %s", err)
+	}
+	f.Close()
+
+	closedAt := time.Now()
+
+	select {
+	case e := <-ch:
+		if e.Path() != p || e.Size() != 1024 {
+			t.Fatalf("[TestCloseWrite] event is different: %s", e)
+		}
+
+		// completed on next tick. polling needs 2 ticks at least.
+		if d := time.Since(closedAt); d > 1200*time.Millisecond {
+			t.Fatalf("[TestCloseWrite] WriteComplete is not sent on close: %s", d)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestCloseWrite] too long to wait for WriteComplete.")
+	}
+
+	if len(*(r.writeNodes)) != 0 {
+		t.Fatalf("[TestCloseWrite] closed file remains in writeNodes.")
+	}
+}
+
+func TestCloseWriteQueued(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/data.bin")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	p := filepath.Join(dir, "upload", "data.bin")
+	if err := ioutil.WriteFile(p, make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("[TestCloseWriteQueued] failed to write file: %s", err)
+	}
+
+	r.addQueue(fsnotify.Event{Name: p, Op: fsnotify.Write}, time.Now())
+	r.wg.Wait()
+
+	// queues are kept for tick.
+	r.addClosed(p)
+
+	if len(*(r.queues)) != 1 || len(ch) != 0 {
+		t.Fatalf("[TestCloseWriteQueued] queues are processed on close. queues: %d, events: %d", len(*(r.queues)), len(ch))
+	}
+
+	// tick
+	r.checkWriteNodes()
+	r.queuesToEvent()
+	r.checkClosed()
+
+	select {
+	case e := <-ch:
+		if e.Op() != WriteComplete || e.Path() != p || e.Size() != 1024 {
+			t.Fatalf("[TestCloseWriteQueued] event is different: %s", e)
+		}
+	default:
+		t.Fatalf("[TestCloseWriteQueued] WriteComplete is not sent on tick.")
+	}
+}
+
+func TestCloseWriteWriting(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/data.bin")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	p := filepath.Join(dir, "upload", "data.bin")
+	if err := ioutil.WriteFile(p, make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("[TestCloseWriteWriting] failed to write file: %s", err)
+	}
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestCloseWriteWriting] cannot find node: %s", err)
+	}
+	r.appendWriteNodes(nodeEvent{Op: Write, node: node})
+
+	// opened files are not scanned on close.
+	r.addClosed(p)
+
+	if len(*(r.writeNodes)) != 1 || len(r.closes) != 1 || len(ch) != 0 {
+		t.Fatalf("[TestCloseWriteWriting] closed file is completed on close.")
+	}
+
+	// tick
+	r.checkWriteNodes()
+	r.queuesToEvent()
+	r.checkClosed()
+	r.sendBatch()
+
+	select {
+	case e := <-ch:
+		if e.Op() != WriteComplete || e.Path() != p || e.Size() != 1024 {
+			t.Fatalf("[TestCloseWriteWriting] event is different: %s", e)
+		}
+	default:
+		t.Fatalf("[TestCloseWriteWriting] WriteComplete is not sent on tick.")
+	}
+
+	if len(*(r.writeNodes)) != 0 || len(r.closes) != 0 {
+		t.Fatalf("[TestCloseWriteWriting] closed file remains after tick.")
+	}
+}
+
+func TestCloseWriteClose(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/.keep")
+	defer os.RemoveAll(dir)
+
+	if err := r.SetCloseWrite(true); err != nil {
+		t.Fatalf("[TestCloseWriteClose] failed to SetCloseWrite: %s", err)
+	}
+
+	w := r.closeWatcher
+
+	r.Close()
+	r.Close()
+	w.close()
+
+	if r.closeWatcher != nil {
+		t.Fatalf("[TestCloseWriteClose] closeWatcher remains after Close.")
+	}
+}
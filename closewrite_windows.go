@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package dirnotify
+
+import (
+	"errors"
+)
+
+// close write is not supported. polling is used.
+type closeWatcher struct{}
+
+func newCloseWatcher(ch chan<- string) (*closeWatcher, error) {
+	return nil, errors.New("[newCloseWatcher] error: close write is not supported.")
+}
+
+func (w *closeWatcher) add(dir string) error {
+	return nil
+}
+
+func (w *closeWatcher) remove(dir string) error {
+	return nil
+}
+
+func (w *closeWatcher) close() error {
+	return nil
+}
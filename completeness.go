@@ -0,0 +1,210 @@
+package dirnotify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	// third party
+	"github.com/satom9to5/fileinfo"
+)
+
+// WriteState is state of file in writing passed to CompletenessStrategy.
+type WriteState struct {
+	Path       string
+	Size       int64
+	ModTime    time.Time
+	Changed    bool      // size or mtime changed since last check
+	LastChange time.Time // last write event or change
+	Closed     bool      // closed after last write (SetCloseWrite)
+	CheckedAt  time.Time
+}
+
+// CompletenessStrategy decides WriteComplete of file in writing.
+// called on every tick and on close write.
+type CompletenessStrategy interface {
+	Complete(st WriteState) bool
+}
+
+type CompletenessFunc func(st WriteState) bool
+
+func (fn CompletenessFunc) Complete(st WriteState) bool {
+	return fn(st)
+}
+
+// strategy per glob pattern.
+type completenessRule struct {
+	pattern  string
+	strategy CompletenessStrategy
+}
+
+// size and mtime unchanged for one tick.
+var defaultCompleteness = StableFor(0)
+
+// StableFor is complete when size and mtime are unchanged for d.
+func StableFor(d time.Duration) CompletenessStrategy {
+	return CompletenessFunc(func(st WriteState) bool {
+		return !st.Changed && st.CheckedAt.Sub(st.LastChange) >= d
+	})
+}
+
+// CloseWrite is complete when writer closed file.
+// SetCloseWrite(true) is required.
+func CloseWrite() CompletenessStrategy {
+	return CompletenessFunc(func(st WriteState) bool {
+		return st.Closed
+	})
+}
+
+// SidecarMarker is complete when marker file exists on same directory.
+// {name} in marker is file name. (e.g. "{name}.done", "_SUCCESS")
+func SidecarMarker(marker string) CompletenessStrategy {
+	return CompletenessFunc(func(st WriteState) bool {
+		_, err := os.Lstat(siblingPath(st.Path, marker))
+		return err == nil
+	})
+}
+
+// LockFileAbsent is complete when lock file does not exist on same directory.
+// {name} in lock is file name. (e.g. "{name}.lock", ".~lock.{name}#")
+func LockFileAbsent(lock string) CompletenessStrategy {
+	return CompletenessFunc(func(st WriteState) bool {
+		_, err := os.Lstat(siblingPath(st.Path, lock))
+		return os.IsNotExist(err)
+	})
+}
+
+// SizeMatches is complete when size is same as declared length.
+// declared returns error when length is unknown yet.
+func SizeMatches(declared func(p string) (int64, error)) CompletenessStrategy {
+	return CompletenessFunc(func(st WriteState) bool {
+		size, err := declared(st.Path)
+		return err == nil && size == st.Size
+	})
+}
+
+func siblingPath(p, name string) string {
+	dir, base := fileinfo.Split(p)
+
+	return dir + fileinfo.PathSep + strings.Replace(name, "{name}", base, -1)
+}
+
+// SetCompleteness sets strategy of WriteComplete on files matched with pattern.
+// pattern is matched with file name, or path from root when it has separator.
+// first added pattern is used when multiple patterns are matched.
+// strategy of same pattern is replaced. (order is kept)
+func (r *Root) SetCompleteness(pattern string, s CompletenessStrategy) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return errors.New("[Root/SetCompleteness] error: " + err.Error())
+	}
+	if s == nil {
+		return errors.New("[Root/SetCompleteness] error: strategy is nil.")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rule := range r.completeness {
+		if rule.pattern == pattern {
+			r.completeness[i].strategy = s
+			return nil
+		}
+	}
+
+	r.completeness = append(r.completeness, completenessRule{pattern, s})
+
+	return nil
+}
+
+// RemoveCompleteness removes strategy of pattern set by SetCompleteness.
+func (r *Root) RemoveCompleteness(pattern string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rule := range r.completeness {
+		if rule.pattern == pattern {
+			r.completeness = append(r.completeness[:i], r.completeness[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("[Root/RemoveCompleteness] error: pattern is not set.")
+}
+
+// SetEmptyWriteComplete sends WriteComplete of empty files. (e.g. "_SUCCESS")
+// default is disabled.
+func (r *Root) SetEmptyWriteComplete(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.emptyComplete = enabled
+}
+
+// nil when no pattern is matched.
+func (r *Root) findCompleteness(p string) CompletenessStrategy {
+	_, name := fileinfo.Split(p)
+
+	for _, rule := range r.completeness {
+		target := name
+		if strings.Contains(rule.pattern, "/") || strings.Contains(rule.pattern, fileinfo.PathSep) {
+			rel, err := filepath.Rel(r.root.Path(), p)
+			if err != nil {
+				continue
+			}
+			target = filepath.ToSlash(rel)
+		}
+
+		if ok, _ := filepath.Match(filepath.ToSlash(rule.pattern), target); ok {
+			return rule.strategy
+		}
+	}
+
+	return nil
+}
+
+// check of WriteComplete on tick and on close write.
+// called when Root.mu locked.
+func (r *Root) isComplete(n *Node, st WriteState) bool {
+	var complete bool
+
+	if s := r.findCompleteness(n.Path()); s != nil {
+		complete = s.Complete(st)
+	} else {
+		// close is complete unless strategy is set.
+		complete = st.Closed || defaultCompleteness.Complete(st)
+	}
+
+	stale := r.stallTimeout > 0 && st.CheckedAt.Sub(st.LastChange) >= r.stallTimeout
+
+	if !complete && !stale {
+		return false
+	}
+
+	// still opened or partial name
+	if !r.incomplete(n.Path()) {
+		return complete
+	}
+
+	n.stalled = stale
+
+	return false
+}
+
+// state for CompletenessStrategy.
+// changed: size or mtime changed since last check.
+func (n *Node) writeState(changed bool) WriteState {
+	if changed {
+		n.lastChange = time.Now()
+	}
+
+	return WriteState{
+		Path:       n.Path(),
+		Size:       n.Size(),
+		ModTime:    n.ModTime(),
+		Changed:    changed,
+		LastChange: n.lastChange,
+		Closed:     n.writeClosed,
+		CheckedAt:  time.Now(),
+	}
+}
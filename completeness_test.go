@@ -0,0 +1,237 @@
+package dirnotify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompletenessStrategy(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "test.csv")
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.csv.done"), nil, 0644); err != nil {
+		t.Fatalf("[TestCompletenessStrategy] failed to write file: %s", err)
+	}
+
+	now := time.Now()
+	declared := func(p string) (int64, error) {
+		return 10, nil
+	}
+	unknown := func(p string) (int64, error) {
+		return 0, errors.New("unknown")
+	}
+
+	patterns := []struct {
+		name     string
+		strategy CompletenessStrategy
+		st       WriteState
+		expect   bool
+	}{
+		{"stable", StableFor(0), WriteState{Path: p, LastChange: now, CheckedAt: now}, true},
+		{"changed", StableFor(0), WriteState{Path: p, Changed: true, LastChange: now, CheckedAt: now}, false},
+		{"not stable for duration", StableFor(time.Second), WriteState{Path: p, LastChange: now, CheckedAt: now}, false},
+		{"stable for duration", StableFor(time.Second), WriteState{Path: p, LastChange: now.Add(-time.Second), CheckedAt: now}, true},
+		{"closed", CloseWrite(), WriteState{Path: p, Closed: true}, true},
+		{"not closed", CloseWrite(), WriteState{Path: p}, false},
+		{"marker", SidecarMarker("{name}.done"), WriteState{Path: p}, true},
+		{"no marker", SidecarMarker("_SUCCESS"), WriteState{Path: p}, false},
+		{"lock", LockFileAbsent("{name}.done"), WriteState{Path: p}, false},
+		{"no lock", LockFileAbsent("{name}.lock"), WriteState{Path: p}, true},
+		{"size matched", SizeMatches(declared), WriteState{Path: p, Size: 10}, true},
+		{"size not matched", SizeMatches(declared), WriteState{Path: p, Size: 9}, false},
+		{"size unknown", SizeMatches(unknown), WriteState{Path: p, Size: 0}, false},
+	}
+
+	for _, pattern := range patterns {
+		if ok := pattern.strategy.Complete(pattern.st); ok != pattern.expect {
+			t.Fatalf("[TestCompletenessStrategy] %s result is different. expect: %t", pattern.name, pattern.expect)
+		}
+	}
+}
+
+func TestSetCompleteness(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "upload"), 0755); err != nil {
+		t.Fatalf("[TestSetCompleteness] failed to create directory: %s", err)
+	}
+
+	csv := filepath.Join(dir, "upload", "test.csv")
+	txt := filepath.Join(dir, "test.txt")
+	for _, p := range []string{csv, txt} {
+		if err := ioutil.WriteFile(p, []byte("test"), 0644); err != nil {
+			t.Fatalf("[TestSetCompleteness] failed to write file: %s", err)
+		}
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestSetCompleteness] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	if err := r.SetCompleteness("[", CloseWrite()); err == nil {
+		t.Fatalf("[TestSetCompleteness] bad pattern is accepted.")
+	}
+	if err := r.SetCompleteness("upload/*.csv", SidecarMarker("{name}.done")); err != nil {
+		t.Fatalf("[TestSetCompleteness] failed to SetCompleteness: %s", err)
+	}
+	if err := r.SetCompleteness("*.csv", CloseWrite()); err != nil {
+		t.Fatalf("[TestSetCompleteness] failed to SetCompleteness: %s", err)
+	}
+
+	if r.findCompleteness(csv) == nil || r.findCompleteness(txt) != nil {
+		t.Fatalf("[TestSetCompleteness] matched pattern is different.")
+	}
+
+	for _, p := range []string{csv, txt} {
+		node, err := r.Find(p)
+		if err != nil {
+			t.Fatalf("[TestSetCompleteness] cannot find node: %s", err)
+		}
+		if err := r.appendWriteNodes(nodeEvent{Op: Write, node: node}); err != nil {
+			t.Fatalf("[TestSetCompleteness] failed to appendWriteNodes: %s", err)
+		}
+	}
+
+	// test.txt is completed by default strategy.
+	r.checkWriteNodes()
+
+	if len(*(r.writeNodes)) != 1 {
+		t.Fatalf("[TestSetCompleteness] write nodes are different. expect: 1, fact: %d", len(*(r.writeNodes)))
+	}
+
+	if err := ioutil.WriteFile(csv+".done", nil, 0644); err != nil {
+		t.Fatalf("[TestSetCompleteness] failed to write file: %s", err)
+	}
+
+	r.checkWriteNodes()
+
+	if len(*(r.writeNodes)) != 0 {
+		t.Fatalf("[TestSetCompleteness] test.csv is not completed.")
+	}
+
+	for _, p := range []string{txt, csv} {
+		select {
+		case e := <-ch:
+			if e.Op() != WriteComplete || e.Path() != p {
+				t.Fatalf("[TestSetCompleteness] event is different. expect: %s, fact: %s", p, e)
+			}
+		default:
+			t.Fatalf("[TestSetCompleteness] WriteComplete of %s is not sent.", p)
+		}
+	}
+}
+
+func TestEmptyWriteComplete(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		dir := tempdir()
+		defer os.RemoveAll(dir)
+
+		p := filepath.Join(dir, "_SUCCESS")
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatalf("[TestEmptyWriteComplete] failed to write file: %s", err)
+		}
+
+		r, err := CreateNodeTree([]string{dir})
+		if err != nil {
+			t.Fatalf("[TestEmptyWriteComplete] cannot create Root: %s", err)
+		}
+		defer r.Close()
+
+		ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 1})
+		defer cancel()
+
+		r.SetEmptyWriteComplete(enabled)
+		r.SetCompleteness("_SUCCESS", LockFileAbsent("{name}.lock"))
+
+		lock := p + ".lock"
+		if err := ioutil.WriteFile(lock, nil, 0644); err != nil {
+			t.Fatalf("[TestEmptyWriteComplete] failed to write file: %s", err)
+		}
+
+		node, err := r.Find(p)
+		if err != nil {
+			t.Fatalf("[TestEmptyWriteComplete] cannot find node: %s", err)
+		}
+		r.appendWriteNodes(nodeEvent{Op: Write, node: node})
+
+		// strategy is applied to empty file.
+		r.checkWriteNodes()
+
+		if len(*(r.writeNodes)) != 1 {
+			t.Fatalf("[TestEmptyWriteComplete] empty file is completed with lock file.")
+		}
+
+		os.Remove(lock)
+		r.checkWriteNodes()
+
+		if len(*(r.writeNodes)) != 0 {
+			t.Fatalf("[TestEmptyWriteComplete] empty file is not completed.")
+		}
+
+		select {
+		case e := <-ch:
+			if !enabled || e.Op() != WriteComplete || e.Path() != p {
+				t.Fatalf("[TestEmptyWriteComplete] event is different. enabled: %t, fact: %s", enabled, e)
+			}
+		default:
+			if enabled {
+				t.Fatalf("[TestEmptyWriteComplete] WriteComplete is not sent.")
+			}
+		}
+	}
+}
+
+// comparable strategy
+type testStrategy string
+
+func (s testStrategy) Complete(st WriteState) bool {
+	return true
+}
+
+func TestSetCompletenessReplace(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/test.csv")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	p := filepath.Join(dir, "upload", "test.csv")
+
+	for _, pattern := range []struct {
+		pattern  string
+		strategy testStrategy
+	}{
+		{"*.csv", "first"},
+		{"upload/*", "other"},
+		{"*.csv", "replaced"},
+	} {
+		if err := r.SetCompleteness(pattern.pattern, pattern.strategy); err != nil {
+			t.Fatalf("[TestSetCompletenessReplace] failed to SetCompleteness: %s", err)
+		}
+	}
+
+	// replaced on first position
+	if s := r.findCompleteness(p); s != testStrategy("replaced") || len(r.completeness) != 2 {
+		t.Fatalf("[TestSetCompletenessReplace] strategy is not replaced: %v", s)
+	}
+
+	if err := r.RemoveCompleteness("*.csv"); err != nil {
+		t.Fatalf("[TestSetCompletenessReplace] failed to RemoveCompleteness: %s", err)
+	}
+	if s := r.findCompleteness(p); s != testStrategy("other") {
+		t.Fatalf("[TestSetCompletenessReplace] strategy is not removed: %v", s)
+	}
+
+	if err := r.RemoveCompleteness("*.csv"); err == nil {
+		t.Fatalf("[TestSetCompletenessReplace] removed pattern is removed again.")
+	}
+}
@@ -0,0 +1,215 @@
+package dirnotify
+
+import (
+	"errors"
+	"time"
+)
+
+// events of same path held in debounce window.
+type debounceEntry struct {
+	path   string
+	start  time.Time // first event observed time
+	events []Event
+}
+
+type debouncer struct {
+	window  time.Duration
+	entries []*debounceEntry // first arrival order
+	index   map[string]*debounceEntry
+	timer   *time.Timer // end of earliest window
+	fire    func(d *debouncer)
+}
+
+func newDebouncer(window time.Duration, fire func(d *debouncer)) *debouncer {
+	return &debouncer{
+		window: window,
+		index:  map[string]*debounceEntry{},
+		fire:   fire,
+	}
+}
+
+// SetDebounce collapses events of same path within window into net result.
+// Create+Write+WriteComplete is Created, Create+Remove is dropped and repeated Writes are merged.
+// window starts at first event of path and is not extended by later events,
+// so events are delayed window at most. held events are sent on Close without waiting blocked consumers.
+// 0 is disabled.
+func (r *Root) SetDebounce(window time.Duration) error {
+	if window < 0 {
+		return errors.New("[Root/SetDebounce] error: window must be 0 or more.")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// send held events before change.
+	if r.debouncer != nil {
+		r.debouncer.stop()
+		r.sendDebounced(r.debouncer.flush(time.Time{}))
+	}
+
+	if window == 0 {
+		r.debouncer = nil
+	} else {
+		r.debouncer = newDebouncer(window, r.flushDebounce)
+	}
+
+	return nil
+}
+
+// send event or hold it on debounce.
+func (r *Root) emit(events []Event, e Event) []Event {
+	// sent after WriteComplete of same path is hashed.
+	if r.checksums.hold(e) {
+		return events
+	}
+
+	if r.debouncer == nil {
+		return append(events, r.send(e))
+	}
+
+	r.debouncer.add(e)
+
+	return events
+}
+
+// send events which window is elapsed.
+// called by timer of d. (nothing to do when d is replaced by SetDebounce)
+func (r *Root) flushDebounce(d *debouncer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.debouncer != d {
+		return
+	}
+
+	d.timer = nil
+	r.sendDebounced(d.flush(time.Now()))
+	d.schedule()
+}
+
+// called when Root.mu locked.
+func (r *Root) sendDebounced(events []Event) {
+	sent := []Event{}
+
+	for _, e := range events {
+		sent = append(sent, r.send(e))
+	}
+
+	r.addBatch(sent)
+}
+
+func (d *debouncer) add(e Event) {
+	de, ok := d.index[e.path]
+	if !ok {
+		de = &debounceEntry{
+			path:  e.path,
+			start: e.observedAt,
+		}
+		if de.start.IsZero() {
+			de.start = time.Now()
+		}
+
+		d.index[e.path] = de
+		d.entries = append(d.entries, de)
+	}
+
+	de.add(e)
+
+	// created and removed in window
+	if len(de.events) == 0 {
+		d.remove(de)
+	}
+
+	d.schedule()
+}
+
+// arm timer for end of earliest window.
+func (d *debouncer) schedule() {
+	if d.timer != nil || len(d.entries) == 0 || d.fire == nil {
+		return
+	}
+
+	start := d.entries[0].start
+	for _, de := range d.entries {
+		if de.start.Before(start) {
+			start = de.start
+		}
+	}
+
+	d.timer = time.AfterFunc(time.Until(start.Add(d.window)), func() {
+		d.fire(d)
+	})
+}
+
+func (d *debouncer) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+// return events of elapsed window.
+// all events when now is zero.
+func (d *debouncer) flush(now time.Time) []Event {
+	events := []Event{}
+	entries := []*debounceEntry{}
+
+	for _, de := range d.entries {
+		if !now.IsZero() && now.Sub(de.start) < d.window {
+			entries = append(entries, de)
+			continue
+		}
+
+		events = append(events, de.events...)
+		delete(d.index, de.path)
+	}
+
+	d.entries = entries
+
+	return events
+}
+
+func (d *debouncer) remove(de *debounceEntry) {
+	delete(d.index, de.path)
+
+	for i, entry := range d.entries {
+		if entry == de {
+			d.entries = append(d.entries[:i], d.entries[i+1:]...)
+			break
+		}
+	}
+}
+
+func (de *debounceEntry) add(e Event) {
+	if len(de.events) == 0 {
+		de.events = append(de.events, e)
+		return
+	}
+
+	first := de.events[0]
+	last := &de.events[len(de.events)-1]
+
+	switch true {
+	case e.op&Remove == Remove && first.op&(Create|Created) > 0:
+		de.events = nil
+	case last.op&(Create|Created) > 0 && e.op == Write:
+		*last = collapseEvent(*last, e, last.op)
+	case last.op == Create && e.op == WriteComplete:
+		*last = collapseEvent(*last, e, Created)
+	case last.op == Write && e.op == Write:
+		*last = collapseEvent(*last, e, Write)
+	default:
+		de.events = append(de.events, e)
+	}
+}
+
+// merged event has attributes of latest, prev and observed time of first.
+func collapseEvent(first, latest Event, op Op) Event {
+	e := latest
+	e.op = op
+	e.beforePath = first.beforePath
+	e.prev = first.prev
+	e.observedAt = first.observedAt
+
+	return e
+}
@@ -0,0 +1,190 @@
+package dirnotify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	r := newTestEventRoot()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 8})
+	defer cancel()
+
+	if err := r.SetDebounce(-1 * time.Second); err == nil {
+		t.Fatalf("[TestDebounce] negative window is accepted.")
+	}
+	if err := r.SetDebounce(2 * time.Second); err != nil {
+		t.Fatalf("[TestDebounce] failed to SetDebounce: %s", err)
+	}
+
+	start := time.Now()
+	prev := &nodeState{size: 1}
+
+	events := []Event{}
+	for _, e := range []Event{
+		Event{op: Create, path: "/tmp/upload.bin", size: 0, observedAt: start},
+		Event{op: Create, path: "/tmp/tmp.bin", observedAt: start},
+		Event{op: Write, path: "/tmp/app.log", size: 2, prev: prev, observedAt: start},
+		Event{op: WriteComplete, path: "/tmp/upload.bin", size: 1024, observedAt: start.Add(time.Second)},
+		Event{op: Remove, path: "/tmp/tmp.bin", observedAt: start.Add(time.Second)},
+		Event{op: Write, path: "/tmp/app.log", size: 3, prev: &nodeState{size: 2}, observedAt: start.Add(time.Second)},
+	} {
+		events = r.emit(events, e)
+	}
+
+	if len(events) != 0 {
+		t.Fatalf("[TestDebounce] events are sent in window: %d", len(events))
+	}
+
+	// in window
+	r.sendDebounced(r.debouncer.flush(start.Add(time.Second)))
+	if len(ch) != 0 {
+		t.Fatalf("[TestDebounce] events are sent in window: %d", len(ch))
+	}
+
+	r.sendDebounced(r.debouncer.flush(start.Add(2 * time.Second)))
+
+	patterns := []struct {
+		Op
+		path     string
+		size     int64
+		prevSize int64
+	}{
+		{Created, "/tmp/upload.bin", 1024, 0},
+		{Write, "/tmp/app.log", 3, 1},
+	}
+
+	for _, pattern := range patterns {
+		select {
+		case e := <-ch:
+			if e.Op() != pattern.Op || e.Path() != pattern.path || e.Size() != pattern.size || e.PrevSize() != pattern.prevSize || !e.ObservedAt().Equal(start) {
+				t.Fatalf("[TestDebounce] event is different: %s, prev size: %d", e, e.PrevSize())
+			}
+		default:
+			t.Fatalf("[TestDebounce] %s is not sent.", pattern.Op)
+		}
+	}
+
+	if len(ch) != 0 {
+		t.Fatalf("[TestDebounce] dropped event is sent: %s", <-ch)
+	}
+
+	// held events are sent on disabled.
+	r.emit(nil, Event{op: Remove, path: "/tmp/app.log"})
+	if err := r.SetDebounce(0); err != nil || r.debouncer != nil {
+		t.Fatalf("[TestDebounce] failed to disable debounce: %v", err)
+	}
+	if e := <-ch; e.Op() != Remove {
+		t.Fatalf("[TestDebounce] held event is different: %s", e)
+	}
+
+	if events = r.emit(nil, Event{op: Create, path: "/tmp/foo"}); len(events) != 1 {
+		t.Fatalf("[TestDebounce] event is not sent without debounce.")
+	}
+}
+
+func TestDebounceTimer(t *testing.T) {
+	r := newTestEventRoot()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 2})
+	defer cancel()
+
+	if err := r.SetDebounce(50 * time.Millisecond); err != nil {
+		t.Fatalf("[TestDebounceTimer] failed to SetDebounce: %s", err)
+	}
+
+	// sent on end of window, not on tick.
+	r.mu.Lock()
+	r.emit(nil, Event{op: Write, path: "/tmp/app.log", observedAt: time.Now()})
+	r.mu.Unlock()
+
+	select {
+	case e := <-ch:
+		if e.Op() != Write || e.Path() != "/tmp/app.log" {
+			t.Fatalf("[TestDebounceTimer] event is different: %s", e)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("[TestDebounceTimer] event is not sent on end of window.")
+	}
+
+	// held events are sent on Close.
+	if err := r.SetDebounce(time.Hour); err != nil {
+		t.Fatalf("[TestDebounceTimer] failed to SetDebounce: %s", err)
+	}
+
+	r.mu.Lock()
+	r.emit(nil, Event{op: Remove, path: "/tmp/app.log", observedAt: time.Now()})
+	r.mu.Unlock()
+
+	r.Close()
+
+	if e, ok := <-ch; !ok || e.Op() != Remove {
+		t.Fatalf("[TestDebounceTimer] held event is not sent on Close.")
+	}
+}
+
+func TestDebounceCloseBlocked(t *testing.T) {
+	r := newTestEventRoot()
+	r.Ch = make(chan Event)
+
+	if err := r.SetDebounce(time.Hour); err != nil {
+		t.Fatalf("[TestDebounceCloseBlocked] failed to SetDebounce: %s", err)
+	}
+
+	r.mu.Lock()
+	r.emit(nil, Event{op: Write, path: "/tmp/app.log", observedAt: time.Now()})
+	r.mu.Unlock()
+
+	// watch loop is blocked on Root.Ch which is not received.
+	sent := make(chan struct{})
+	go func() {
+		r.mu.Lock()
+		r.send(Event{op: Create, path: "/tmp/foo"})
+		r.mu.Unlock()
+		close(sent)
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("[TestDebounceCloseBlocked] Close waits blocked sender.")
+	}
+
+	<-sent
+}
+
+func TestDebounceStaleTimer(t *testing.T) {
+	r := newTestEventRoot()
+	defer r.Close()
+
+	if err := r.SetDebounce(time.Hour); err != nil {
+		t.Fatalf("[TestDebounceStaleTimer] failed to SetDebounce: %s", err)
+	}
+	old := r.debouncer
+
+	if err := r.SetDebounce(time.Hour); err != nil {
+		t.Fatalf("[TestDebounceStaleTimer] failed to SetDebounce: %s", err)
+	}
+
+	r.mu.Lock()
+	r.emit(nil, Event{op: Write, path: "/tmp/app.log", observedAt: time.Now()})
+	timer := r.debouncer.timer
+	r.mu.Unlock()
+
+	// timer of replaced debouncer fired while SetDebounce.
+	r.flushDebounce(old)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.debouncer.timer != timer || len(r.debouncer.entries) != 1 {
+		t.Fatalf("[TestDebounceStaleTimer] stale timer changed current debouncer.")
+	}
+}
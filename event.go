@@ -1,30 +1,71 @@
 package dirnotify
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
 	Create Op = 1 << iota
-	Remove
-	Rename
+	Remove    // removed, or moved out of root
+	Rename    // renamed in same directory
 	Write
 	Chmod
-	Move
+	Move // moved to other directory
 	WriteComplete
+	Attrib        // mode, owner or mtime changed
+	Replace       // renamed or created over existing file
+	Truncate      // size decreased while writing
+	Link          // new hard link of watched file
+	Relink        // target of symbolic link changed
+	Created       // Create, Write and WriteComplete collapsed (debounce)
+	WriteProgress // periodic while writing large file
+	WriteStalled  // unchanged for timeout but still incomplete
 )
 
 type Op uint32
 
 type Event struct {
-	op         Op
-	path       string
-	beforePath string
-	size       int64
-	modTime    time.Time
-	isDir      bool
+	op            Op
+	path          string
+	beforePath    string
+	size          int64
+	modTime       time.Time
+	isDir         bool
+	ino           uint64
+	dev           uint64
+	mode          os.FileMode
+	uid           uint32
+	gid           uint32
+	nlink         uint64
+	ctime         time.Time
+	seq           uint64     // sequence number per Root
+	correlationID uint64     // same on all events of Node
+	observedAt    time.Time  // fsnotify event received time
+	deliveredAt   time.Time  // sent time to channel
+	prev          *nodeState // before change (Write, WriteComplete, WriteProgress, WriteStalled, Attrib, Rename, Move, Replace, Truncate)
+
+	// overwritten file (Replace)
+	replacedIno  uint64
+	replacedPath string
+
+	links []string // other hard links (Link)
+
+	// content hash (WriteComplete)
+	checksum          string // hex encoded
+	checksumAlgorithm HashAlgorithm
+
+	// symbolic link destination
+	linkTarget     string
+	prevLinkTarget string // before changed (Relink)
+
+	// transfer rate (WriteProgress)
+	bytesPerSecond float64
+	writeElapsed   time.Duration // since first write
 }
 
 func newEvent(ne nodeEvent) Event {
@@ -33,14 +74,20 @@ func newEvent(ne nodeEvent) Event {
 		return Event{}
 	}
 
-	return Event{
-		op:         ne.Op,
-		path:       node.Path(),
-		beforePath: ne.beforePath,
-		size:       node.Size(),
-		modTime:    node.ModTime(),
-		isDir:      node.IsDir(),
+	e := newEventByOpNode(ne.Op, node)
+	e.beforePath = ne.beforePath
+	e.observedAt = ne.observedAt
+	e.replacedIno = ne.replacedIno
+	e.replacedPath = ne.replacedPath
+	e.links = ne.links
+	if ne.Op&Relink == Relink {
+		e.prevLinkTarget = ne.prevLinkTarget
 	}
+	if ne.Op&(Write|WriteComplete|Attrib|Rename|Move|Replace|Truncate) > 0 {
+		e.prev = ne.prev
+	}
+
+	return e
 }
 
 func newEventByOpNode(op Op, node *Node) Event {
@@ -49,7 +96,18 @@ func newEventByOpNode(op Op, node *Node) Event {
 		path:    node.Path(),
 		size:    node.Size(),
 		modTime: node.ModTime(),
-		isDir:   node.IsDir(),
+		isDir:   node.Mode().IsDir(),
+		ino:     node.Ino(),
+		dev:     node.Dev(),
+		mode:    node.Mode(),
+		uid:     node.Uid(),
+		gid:     node.Gid(),
+		nlink:   node.Nlink(),
+		ctime:   node.Ctime(),
+
+		correlationID: node.CorrelationID(),
+
+		linkTarget: node.LinkTarget(),
 	}
 }
 
@@ -65,21 +123,93 @@ var (
 		{Chmod, "Chmod"},
 		{Move, "Move"},
 		{WriteComplete, "WriteComplete"},
+		{Attrib, "Attrib"},
+		{Replace, "Replace"},
+		{Truncate, "Truncate"},
+		{Link, "Link"},
+		{Relink, "Relink"},
+		{Created, "Created"},
+		{WriteProgress, "WriteProgress"},
+		{WriteStalled, "WriteStalled"},
 	}
 )
 
+// unknown bits are hex. (e.g. "Create|0x80000000")
 func flagString(op Op) string {
 	flags := []string{}
 
 	for _, f := range flagList {
 		if op&f.Op == f.Op {
 			flags = append(flags, f.name)
+			op &^= f.Op
 		}
 	}
 
+	if op != 0 {
+		flags = append(flags, fmt.Sprintf("0x%x", uint32(op)))
+	}
+
 	return strings.Join(flags, "|")
 }
 
+func (op Op) String() string {
+	return flagString(op)
+}
+
+// ParseOp parses Op names joined by "|". (e.g. "Create|Move")
+// hex is parsed as bits. (e.g. "0x80000000")
+func ParseOp(str string) (Op, error) {
+	var op Op
+
+	if strings.TrimSpace(str) == "" {
+		return op, nil
+	}
+
+	for _, name := range strings.Split(str, "|") {
+		name = strings.TrimSpace(name)
+		found := false
+
+		if strings.HasPrefix(name, "0x") || strings.HasPrefix(name, "0X") {
+			bits, err := strconv.ParseUint(name[2:], 16, 32)
+			if err != nil {
+				return 0, errors.New(fmt.Sprintf("[ParseOp] error: invalid Op bits: %s", name))
+			}
+
+			op |= Op(bits)
+			continue
+		}
+
+		for _, f := range flagList {
+			if strings.EqualFold(f.name, name) {
+				op |= f.Op
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, errors.New(fmt.Sprintf("[ParseOp] error: unknown Op name: %s", name))
+		}
+	}
+
+	return op, nil
+}
+
+func (op Op) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+func (op *Op) UnmarshalText(text []byte) error {
+	parsed, err := ParseOp(string(text))
+	if err != nil {
+		return err
+	}
+
+	*op = parsed
+
+	return nil
+}
+
 func (e Event) String() string {
 	t := "file"
 	if e.isDir {
@@ -90,6 +220,9 @@ func (e Event) String() string {
 	if e.beforePath != "" {
 		str += fmt.Sprintf("BeforePath: %s, ", e.beforePath)
 	}
+	if e.linkTarget != "" {
+		str += fmt.Sprintf("LinkTarget: %s, ", e.linkTarget)
+	}
 	return str + fmt.Sprintf("Size: %d, ModTime: %s, Type: %s", e.size, e.modTime.String(), t)
 }
 
@@ -116,3 +249,135 @@ func (e Event) ModTime() time.Time {
 func (e Event) IsDir() bool {
 	return e.isDir
 }
+
+func (e Event) Ino() uint64 {
+	return e.ino
+}
+
+func (e Event) Dev() uint64 {
+	return e.dev
+}
+
+func (e Event) Mode() os.FileMode {
+	return e.mode
+}
+
+func (e Event) Uid() uint32 {
+	return e.uid
+}
+
+func (e Event) Gid() uint32 {
+	return e.gid
+}
+
+func (e Event) Nlink() uint64 {
+	return e.nlink
+}
+
+func (e Event) Ctime() time.Time {
+	return e.ctime
+}
+
+func (e Event) Seq() uint64 {
+	return e.seq
+}
+
+// CorrelationID returns ID of Node. same from Create to Remove, kept across Rename/Move.
+func (e Event) CorrelationID() uint64 {
+	return e.correlationID
+}
+
+func (e Event) ObservedAt() time.Time {
+	return e.observedAt
+}
+
+func (e Event) DeliveredAt() time.Time {
+	return e.deliveredAt
+}
+
+// PrevSize returns size before change.
+// Prev* values are zero except Write, WriteComplete, WriteProgress, WriteStalled, Attrib, Rename, Move, Replace and Truncate.
+func (e Event) PrevSize() int64 {
+	if e.prev == nil {
+		return 0
+	}
+
+	return e.prev.size
+}
+
+func (e Event) PrevMode() os.FileMode {
+	if e.prev == nil {
+		return 0
+	}
+
+	return e.prev.mode
+}
+
+func (e Event) PrevUid() uint32 {
+	if e.prev == nil {
+		return 0
+	}
+
+	return e.prev.uid
+}
+
+func (e Event) PrevGid() uint32 {
+	if e.prev == nil {
+		return 0
+	}
+
+	return e.prev.gid
+}
+
+func (e Event) PrevModTime() time.Time {
+	if e.prev == nil {
+		return time.Time{}
+	}
+
+	return e.prev.modTime
+}
+
+// ReplacedIno returns inode of overwritten file. (Replace)
+func (e Event) ReplacedIno() uint64 {
+	return e.replacedIno
+}
+
+// ReplacedPath returns path of overwritten file. (Replace)
+func (e Event) ReplacedPath() string {
+	return e.replacedPath
+}
+
+// Links returns other hard links of same inode. (Link)
+func (e Event) Links() []string {
+	return e.links
+}
+
+// Checksum returns hex encoded content hash. (WriteComplete)
+// empty when disabled or file is larger than limit.
+func (e Event) Checksum() string {
+	return e.checksum
+}
+
+func (e Event) ChecksumAlgorithm() HashAlgorithm {
+	return e.checksumAlgorithm
+}
+
+// LinkTarget returns destination of symbolic link. empty when not symbolic link.
+func (e Event) LinkTarget() string {
+	return e.linkTarget
+}
+
+// PrevLinkTarget returns destination before changed. (Relink)
+func (e Event) PrevLinkTarget() string {
+	return e.prevLinkTarget
+}
+
+// BytesPerSecond returns write rate since last WriteProgress. (WriteProgress)
+func (e Event) BytesPerSecond() float64 {
+	return e.bytesPerSecond
+}
+
+// WriteElapsed returns time since first write. (WriteProgress)
+func (e Event) WriteElapsed() time.Duration {
+	return e.writeElapsed
+}
@@ -0,0 +1,213 @@
+package dirnotify
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+/**
+ * Event JSON schema
+ *
+ * {
+ *   "op":         "Create|Move",                      // Op names joined by "|"
+ *   "path":       "/root/foo/bar.txt",
+ *   "beforePath": "/root/bar.txt",                    // omitted when empty
+ *   "size":       1024,
+ *   "modTime":    "2017-07-01T23:50:59.123456789Z",   // RFC 3339 with nanoseconds
+ *   "isDir":      false,
+ *   "ino":        1234,
+ *   "dev":        2049,
+ *   "mode":       420,                                // os.FileMode bits
+ *   "uid":        1000,
+ *   "gid":        1000,
+ *   "nlink":      1,
+ *   "ctime":      "2017-07-01T23:50:59.123456789Z",   // RFC 3339 with nanoseconds
+ *   "seq":        42,                                 // sequence number per Root
+ *   "correlationId": 7,                               // same on all events of file, omitted when empty
+ *   "observedAt": "2017-07-01T23:50:59.123456789Z",   // RFC 3339 with nanoseconds
+ *   "deliveredAt": "2017-07-01T23:51:00.123456789Z",  // RFC 3339 with nanoseconds
+ *   "replacedIno":  5678,                             // overwritten file (Replace), omitted when empty
+ *   "replacedPath": "/root/foo/bar.txt",              // overwritten file (Replace), omitted when empty
+ *   "links":  ["/root/bar.txt"],                      // other hard links (Link), omitted when empty
+ *   "checksum": "9f86d081...",                        // hex encoded content hash (WriteComplete), omitted when empty
+ *   "checksumAlgorithm": "sha256",                    // "sha256" or "xxhash", omitted when empty
+ *   "linkTarget":     "../foo",                       // symbolic link destination, omitted when empty
+ *   "prevLinkTarget": "../bar",                       // destination before changed (Relink), omitted when empty
+ *   "bytesPerSecond": 1048576.5,                      // write rate (WriteProgress), omitted when empty
+ *   "writeElapsed":   3000000000,                     // nanoseconds since first write (WriteProgress), omitted when empty
+ *   "prev": {                                         // before change, omitted when empty
+ *     "size":     512,
+ *     "modTime":  "2017-07-01T23:50:59.123456789Z",
+ *     "mode":     420,
+ *     "uid":      1000,
+ *     "gid":      1000
+ *   }
+ * }
+ *
+ * empty time string is decoded as zero time.
+ */
+
+type eventJSON struct {
+	Op                Op            `json:"op"`
+	Path              string        `json:"path"`
+	BeforePath        string        `json:"beforePath,omitempty"`
+	Size              int64         `json:"size"`
+	ModTime           string        `json:"modTime"`
+	IsDir             bool          `json:"isDir"`
+	Ino               uint64        `json:"ino"`
+	Dev               uint64        `json:"dev"`
+	Mode              uint32        `json:"mode"`
+	Uid               uint32        `json:"uid"`
+	Gid               uint32        `json:"gid"`
+	Nlink             uint64        `json:"nlink"`
+	Ctime             string        `json:"ctime"`
+	Seq               uint64        `json:"seq"`
+	CorrelationID     uint64        `json:"correlationId,omitempty"`
+	ObservedAt        string        `json:"observedAt"`
+	DeliveredAt       string        `json:"deliveredAt"`
+	ReplacedIno       uint64        `json:"replacedIno,omitempty"`
+	ReplacedPath      string        `json:"replacedPath,omitempty"`
+	Links             []string      `json:"links,omitempty"`
+	Checksum          string        `json:"checksum,omitempty"`
+	ChecksumAlgorithm HashAlgorithm `json:"checksumAlgorithm,omitempty"`
+	LinkTarget        string        `json:"linkTarget,omitempty"`
+	PrevLinkTarget    string        `json:"prevLinkTarget,omitempty"`
+	BytesPerSecond    float64       `json:"bytesPerSecond,omitempty"`
+	WriteElapsed      int64         `json:"writeElapsed,omitempty"`
+	Prev              *stateJSON    `json:"prev,omitempty"`
+}
+
+type stateJSON struct {
+	Size    int64  `json:"size"`
+	ModTime string `json:"modTime"`
+	Mode    uint32 `json:"mode"`
+	Uid     uint32 `json:"uid"`
+	Gid     uint32 `json:"gid"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	var prev *stateJSON
+	if e.prev != nil {
+		prev = &stateJSON{
+			Size:    e.prev.size,
+			ModTime: e.prev.modTime.Format(time.RFC3339Nano),
+			Mode:    uint32(e.prev.mode),
+			Uid:     e.prev.uid,
+			Gid:     e.prev.gid,
+		}
+	}
+
+	return json.Marshal(eventJSON{
+		Op:                e.op,
+		Path:              e.path,
+		BeforePath:        e.beforePath,
+		Size:              e.size,
+		ModTime:           e.modTime.Format(time.RFC3339Nano),
+		IsDir:             e.isDir,
+		Ino:               e.ino,
+		Dev:               e.dev,
+		Mode:              uint32(e.mode),
+		Uid:               e.uid,
+		Gid:               e.gid,
+		Nlink:             e.nlink,
+		Ctime:             e.ctime.Format(time.RFC3339Nano),
+		Seq:               e.seq,
+		CorrelationID:     e.correlationID,
+		ObservedAt:        e.observedAt.Format(time.RFC3339Nano),
+		DeliveredAt:       e.deliveredAt.Format(time.RFC3339Nano),
+		ReplacedIno:       e.replacedIno,
+		ReplacedPath:      e.replacedPath,
+		Links:             e.links,
+		Checksum:          e.checksum,
+		ChecksumAlgorithm: e.checksumAlgorithm,
+		LinkTarget:        e.linkTarget,
+		PrevLinkTarget:    e.prevLinkTarget,
+		BytesPerSecond:    e.bytesPerSecond,
+		WriteElapsed:      int64(e.writeElapsed),
+		Prev:              prev,
+	})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	ej := eventJSON{}
+	if err := json.Unmarshal(data, &ej); err != nil {
+		return err
+	}
+
+	modTime, err := parseTime(ej.ModTime)
+	if err != nil {
+		return err
+	}
+
+	ctime, err := parseTime(ej.Ctime)
+	if err != nil {
+		return err
+	}
+
+	observedAt, err := parseTime(ej.ObservedAt)
+	if err != nil {
+		return err
+	}
+
+	deliveredAt, err := parseTime(ej.DeliveredAt)
+	if err != nil {
+		return err
+	}
+
+	var prev *nodeState
+	if ej.Prev != nil {
+		prevModTime, err := parseTime(ej.Prev.ModTime)
+		if err != nil {
+			return err
+		}
+
+		prev = &nodeState{
+			size:    ej.Prev.Size,
+			modTime: prevModTime,
+			mode:    os.FileMode(ej.Prev.Mode),
+			uid:     ej.Prev.Uid,
+			gid:     ej.Prev.Gid,
+		}
+	}
+
+	*e = Event{
+		op:                ej.Op,
+		path:              ej.Path,
+		beforePath:        ej.BeforePath,
+		size:              ej.Size,
+		modTime:           modTime,
+		isDir:             ej.IsDir,
+		ino:               ej.Ino,
+		dev:               ej.Dev,
+		mode:              os.FileMode(ej.Mode),
+		uid:               ej.Uid,
+		gid:               ej.Gid,
+		nlink:             ej.Nlink,
+		ctime:             ctime,
+		seq:               ej.Seq,
+		correlationID:     ej.CorrelationID,
+		observedAt:        observedAt,
+		deliveredAt:       deliveredAt,
+		replacedIno:       ej.ReplacedIno,
+		replacedPath:      ej.ReplacedPath,
+		links:             ej.Links,
+		checksum:          ej.Checksum,
+		checksumAlgorithm: ej.ChecksumAlgorithm,
+		linkTarget:        ej.LinkTarget,
+		prevLinkTarget:    ej.PrevLinkTarget,
+		bytesPerSecond:    ej.BytesPerSecond,
+		writeElapsed:      time.Duration(ej.WriteElapsed),
+		prev:              prev,
+	}
+
+	return nil
+}
+
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, str)
+}
@@ -0,0 +1,57 @@
+package dirnotify
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEventJSON(t *testing.T) {
+	modTime := time.Date(2017, 7, 1, 23, 50, 59, 123456789, time.UTC)
+
+	e := Event{
+		op:            Move,
+		path:          "/tmp/usr/local/bin/ls.exe",
+		beforePath:    "/tmp/usr/bin/ls.exe",
+		size:          1024,
+		modTime:       modTime,
+		ino:           1234,
+		dev:           2049,
+		mode:          0644,
+		uid:           1000,
+		gid:           1000,
+		nlink:         1,
+		ctime:         modTime,
+		seq:           42,
+		correlationID: 7,
+		observedAt:    modTime,
+		deliveredAt:   modTime,
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("[TestEventJSON] failed to marshal: %s", err)
+	}
+
+	expect := `{"op":"Move","path":"/tmp/usr/local/bin/ls.exe","beforePath":"/tmp/usr/bin/ls.exe","size":1024,"modTime":"2017-07-01T23:50:59.123456789Z","isDir":false,"ino":1234,"dev":2049,"mode":420,"uid":1000,"gid":1000,"nlink":1,"ctime":"2017-07-01T23:50:59.123456789Z","seq":42,"correlationId":7,"observedAt":"2017-07-01T23:50:59.123456789Z","deliveredAt":"2017-07-01T23:50:59.123456789Z"}`
+	if string(data) != expect {
+		t.Fatalf("[TestEventJSON] json is different. expect: %s, fact: %s", expect, data)
+	}
+
+	var decoded Event
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("[TestEventJSON] failed to unmarshal: %s", err)
+	}
+
+	if decoded.Op() != e.Op() || decoded.Path() != e.Path() || decoded.BeforePath() != e.BeforePath() ||
+		decoded.Size() != e.Size() || !decoded.ModTime().Equal(e.ModTime()) || decoded.IsDir() != e.IsDir() ||
+		decoded.Ino() != e.Ino() || decoded.Dev() != e.Dev() || decoded.Mode() != e.Mode() ||
+		decoded.Uid() != e.Uid() || decoded.Gid() != e.Gid() || decoded.Nlink() != e.Nlink() || !decoded.Ctime().Equal(e.Ctime()) ||
+		decoded.Seq() != e.Seq() || decoded.CorrelationID() != e.CorrelationID() || !decoded.ObservedAt().Equal(e.ObservedAt()) || !decoded.DeliveredAt().Equal(e.DeliveredAt()) {
+		t.Fatalf("[TestEventJSON] decoded event is different: %s : %s", e, decoded)
+	}
+
+	if err = json.Unmarshal([]byte(`{"op":"Copy"}`), &decoded); err == nil {
+		t.Fatalf("[TestEventJSON] unknown Op is accepted.")
+	}
+}
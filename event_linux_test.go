@@ -0,0 +1,75 @@
+package dirnotify
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestEventFileInfo(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "ls.exe")
+	if f, err := os.Create(p); err != nil {
+		t.Fatalf("[TestEventFileInfo] failed to create file: %s", err)
+	} else {
+		f.Close()
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestEventFileInfo] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestEventFileInfo] failed to Root/Find: %s", err)
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("[TestEventFileInfo] failed to stat: %s", err)
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Fatalf("[TestEventFileInfo] failed to get syscall.Stat_t.")
+	}
+
+	e := newEventByOpNode(Create, node)
+
+	if e.Ino() != st.Ino || e.Dev() != uint64(st.Dev) || e.Mode() != fi.Mode() ||
+		e.Uid() != st.Uid || e.Gid() != st.Gid || e.Nlink() != uint64(st.Nlink) || e.Ctime().IsZero() {
+		t.Fatalf("[TestEventFileInfo] file info is different: %+v : %+v", e, st)
+	}
+}
+
+func TestEventFileInfoReplaced(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "ls.exe")
+	if f, err := os.Create(p); err != nil {
+		t.Fatalf("[TestEventFileInfoReplaced] failed to create file: %s", err)
+	} else {
+		f.Close()
+	}
+
+	_, ino, _, err := statSys(p)
+	if err != nil {
+		t.Fatalf("[TestEventFileInfoReplaced] failed to stat: %s", err)
+	}
+
+	// sysInfo of other file which is replaced before fileinfo.Stat.
+	fi, sys, err := sameStatInfo(p, statSys, ino+1, &sysInfo{nlink: 2})
+	if err != nil {
+		t.Fatalf("[TestEventFileInfoReplaced] failed to stat again: %s", err)
+	}
+
+	if fi.Ino() != ino || sys == nil || sys.nlink != 1 {
+		t.Fatalf("[TestEventFileInfoReplaced] sysInfo is not same file: %+v", sys)
+	}
+}
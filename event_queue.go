@@ -4,16 +4,19 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 	// third party
 	"github.com/satom9to5/fileinfo"
 	"github.com/satom9to5/fsnotify"
 )
 
 type eventQueue struct {
-	Op   Op
-	dir  string // parent directory path
-	base string // target file or directory
-	node *Node
+	Op         Op
+	dir        string // parent directory path
+	base       string // target file or directory
+	node       *Node
+	observedAt time.Time // fsnotify event received time
+	unlinked   bool      // Remove is not paired with Create (inode may be reused)
 }
 
 type eventQueues []eventQueue
@@ -37,7 +40,13 @@ func (eqs *eventQueues) clear() {
 }
 
 func (eqs *eventQueues) add(e fsnotify.Event, r *Root) {
-	eq := eventQueue{}
+	eqs.addObserved(e, time.Now(), r)
+}
+
+func (eqs *eventQueues) addObserved(e fsnotify.Event, observedAt time.Time, r *Root) {
+	eq := eventQueue{
+		observedAt: observedAt,
+	}
 	eq.dir, eq.base = fileinfo.Split(e.Name)
 
 	switch true {
@@ -47,6 +56,7 @@ func (eqs *eventQueues) add(e fsnotify.Event, r *Root) {
 		eq.Op |= Create
 	case e.Op&fsnotify.Remove == fsnotify.Remove:
 		eq.Op |= Remove
+		eq.unlinked = removeIsUnlink
 	case e.Op&fsnotify.Rename == fsnotify.Rename:
 		eq.Op |= Rename
 	case e.Op&fsnotify.Chmod == fsnotify.Chmod:
@@ -59,6 +69,20 @@ func (eqs *eventQueues) add(e fsnotify.Event, r *Root) {
 	}
 
 	*eqs = append(*eqs, eq)
+
+	// target of followed link is changed. (Create is replaced target)
+	if eq.Op&(Write|Create|Chmod) > 0 && len(r.linkTargets) > 0 {
+		for _, link := range r.targetLinks(e.Name) {
+			leq := eq
+			leq.dir, leq.base = link.Dir(), link.Name()
+			leq.node = link
+			if leq.Op&Create == Create {
+				leq.Op = Write
+			}
+
+			*eqs = append(*eqs, leq)
+		}
+	}
 }
 
 func (eqs *eventQueues) addFromNodes(nodes []*Node) {
@@ -71,10 +95,11 @@ func (eqs *eventQueues) addFromNodes(nodes []*Node) {
 
 func (eqs *eventQueues) addFromNode(n *Node, op Op) {
 	eq := eventQueue{
-		Op:   op,
-		dir:  n.Dir(),
-		base: n.Name(),
-		node: n,
+		Op:         op,
+		dir:        n.Dir(),
+		base:       n.Name(),
+		node:       n,
+		observedAt: time.Now(),
 	}
 
 	*eqs = append(*eqs, eq)
@@ -103,6 +128,10 @@ func (eqs *eventQueues) createNodeEvents(r *Root) (*nodeEvents, error) {
 	// check Move event.
 	nes.updateOp()
 
+	if r.atomicSave {
+		nes.atomicSave()
+	}
+
 	return nes, nil
 }
 
@@ -138,6 +167,45 @@ func (eqs *eventQueues) Less(i, j int) bool {
 	}
 }
 
+func (eqs *eventQueues) has(op Op, p string) bool {
+	for _, eq := range *eqs {
+		if eq.Op&op == op && eq.Path() == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check unlinked Remove on p.
+func (eqs *eventQueues) unlinked(p string) bool {
+	for _, eq := range *eqs {
+		if eq.unlinked && eq.Path() == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove all queues of op on p and return first one.
+// (e.g. Remove of directory is sent from itself and parent)
+func (eqs *eventQueues) take(op Op, p string) (taken eventQueue, ok bool) {
+	rest := eventQueues{}
+
+	for _, eq := range *eqs {
+		if eq.Op&op != op || eq.Path() != p {
+			rest = append(rest, eq)
+		} else if !ok {
+			taken, ok = eq, true
+		}
+	}
+
+	*eqs = rest
+
+	return
+}
+
 // rename path
 func (eqs *eventQueues) rename(from, to string) {
 	for i, eq := range *eqs {
@@ -1,8 +1,12 @@
 package dirnotify
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
+	// third party
+	"github.com/satom9to5/fsnotify"
 )
 
 func TestEventQueue(t *testing.T) {
@@ -56,3 +60,24 @@ func createTestEventQueues() eventQueues {
 		eventQueue{Op: Create, dir: filepath.FromSlash("/usr/bin"), base: "more"},
 	}
 }
+
+func TestEventQueueObservedAt(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/data.bin")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	p := filepath.Join(dir, "upload", "data.bin")
+	observedAt := time.Now()
+
+	// Root.mu is held on delivery to blocked subscriber.
+	r.mu.Lock()
+	r.addQueue(fsnotify.Event{Name: p, Op: fsnotify.Write}, observedAt)
+	time.Sleep(100 * time.Millisecond)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+
+	if len(*(r.queues)) != 1 || !(*(r.queues))[0].observedAt.Equal(observedAt) {
+		t.Fatalf("[TestEventQueueObservedAt] observed time is not received time: %v", *(r.queues))
+	}
+}
@@ -0,0 +1,54 @@
+package dirnotify
+
+import (
+	"testing"
+)
+
+func TestParseOp(t *testing.T) {
+	patterns := []struct {
+		str string
+		op  Op
+	}{
+		{"", 0},
+		{"Create", Create},
+		{"Create|Move", Create | Move},
+		{"remove | writecomplete", Remove | WriteComplete},
+	}
+
+	for _, pattern := range patterns {
+		op, err := ParseOp(pattern.str)
+		if err != nil {
+			t.Fatalf("[TestParseOp] failed to parse %s: %s", pattern.str, err)
+		}
+		if op != pattern.op {
+			t.Fatalf("[TestParseOp] Op is different. expect: %s, fact: %s", pattern.op, op)
+		}
+	}
+
+	if _, err := ParseOp("Create|Copy"); err == nil {
+		t.Fatalf("[TestParseOp] unknown Op name is accepted.")
+	}
+	if _, err := ParseOp("Create|0xz"); err == nil {
+		t.Fatalf("[TestParseOp] invalid Op bits are accepted.")
+	}
+
+	// text round trip
+	text, err := (Create | WriteComplete).MarshalText()
+	if err != nil || string(text) != "Create|WriteComplete" {
+		t.Fatalf("[TestParseOp] failed to MarshalText: %s, %v", text, err)
+	}
+
+	var op Op
+	if err = op.UnmarshalText(text); err != nil || op != Create|WriteComplete {
+		t.Fatalf("[TestParseOp] failed to UnmarshalText: %s, %v", op, err)
+	}
+
+	// unknown bits round trip
+	unknown := Create | Op(1<<31)
+	if text, err = unknown.MarshalText(); err != nil || string(text) != "Create|0x80000000" {
+		t.Fatalf("[TestParseOp] failed to MarshalText unknown bits: %s, %v", text, err)
+	}
+	if err = op.UnmarshalText(text); err != nil || op != unknown {
+		t.Fatalf("[TestParseOp] failed to UnmarshalText unknown bits: %s, %v", op, err)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/satom9to5/dirnotify
 go 1.13
 
 require (
+	github.com/cespare/xxhash/v2 v2.1.2
 	github.com/satom9to5/fileinfo v0.0.0-20170701235059-df85bfdfeff5
 	github.com/satom9to5/fsnotify v1.4.2
 	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527 // indirect
@@ -0,0 +1,220 @@
+package dirnotify
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	defaultHandlerWorkers = 1
+	handlerQueueSize      = 64
+)
+
+type HandlerFunc func(Event) error
+
+type handler struct {
+	Op
+	fn HandlerFunc
+}
+
+type handlers struct {
+	list    []handler
+	workers int
+	queue   chan Event
+	done    chan struct{}
+	started bool
+	stopped bool
+	mu      sync.RWMutex
+}
+
+func newHandlers() *handlers {
+	return &handlers{
+		workers: defaultHandlerWorkers,
+		queue:   make(chan Event, handlerQueueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+func (hs *handlers) add(op Op, fn HandlerFunc) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	hs.list = append(hs.list, handler{op, fn})
+}
+
+func (hs *handlers) len() int {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+
+	return len(hs.list)
+}
+
+func (hs *handlers) setWorkers(n int) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if n < 1 {
+		return errors.New("[handlers/setWorkers] error: workers must be 1 or more.")
+	}
+	if hs.started {
+		return errors.New("[handlers/setWorkers] error: workers already started.")
+	}
+
+	hs.workers = n
+
+	return nil
+}
+
+// start workers on first dispatch.
+func (hs *handlers) dispatch(e Event, r *Root) {
+	hs.mu.Lock()
+	if len(hs.list) == 0 || hs.stopped {
+		hs.mu.Unlock()
+		return
+	}
+	if !hs.started {
+		hs.started = true
+
+		for i := 0; i < hs.workers; i++ {
+			go hs.work(r)
+		}
+	}
+	hs.mu.Unlock()
+
+	select {
+	case hs.queue <- e:
+	case <-hs.done:
+	case <-r.done:
+	}
+}
+
+func (hs *handlers) work(r *Root) {
+	for {
+		select {
+		case e := <-hs.queue:
+			hs.run(e, r)
+		case <-hs.done:
+			return
+		}
+	}
+}
+
+func (hs *handlers) run(e Event, r *Root) {
+	hs.mu.RLock()
+	list := make([]handler, len(hs.list))
+	copy(list, hs.list)
+	hs.mu.RUnlock()
+
+	for _, h := range list {
+		if h.Op != 0 && e.op&h.Op == 0 {
+			continue
+		}
+
+		if err := h.call(e); err != nil {
+			r.sendError(err)
+		}
+	}
+}
+
+func (hs *handlers) stop() {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if hs.stopped {
+		return
+	}
+
+	hs.stopped = true
+	close(hs.done)
+}
+
+// recover panic on handler.
+func (h handler) call(e Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = newPanicError("handler/call", p)
+		}
+	}()
+
+	if err = h.fn(e); err != nil {
+		err = errors.New(fmt.Sprintf("[handler/call] error: %s, event: {%s}", err, e))
+	}
+
+	return
+}
+
+// Handle registers handler called on matched Op events. (0 is all events.)
+// handler errors and panics are sent to Root.Errors.
+func (r *Root) Handle(op Op, fn func(Event) error) {
+	r.handlers.add(op, fn)
+}
+
+func (r *Root) OnCreate(fn func(Event)) {
+	r.Handle(Create, ignoreError(fn))
+}
+
+func (r *Root) OnRemove(fn func(Event)) {
+	r.Handle(Remove, ignoreError(fn))
+}
+
+func (r *Root) OnRename(fn func(Event)) {
+	r.Handle(Rename, ignoreError(fn))
+}
+
+func (r *Root) OnWrite(fn func(Event)) {
+	r.Handle(Write, ignoreError(fn))
+}
+
+func (r *Root) OnMove(fn func(Event)) {
+	r.Handle(Move, ignoreError(fn))
+}
+
+func (r *Root) OnWriteComplete(fn func(Event)) {
+	r.Handle(WriteComplete, ignoreError(fn))
+}
+
+func (r *Root) OnAttrib(fn func(Event)) {
+	r.Handle(Attrib, ignoreError(fn))
+}
+
+func (r *Root) OnReplace(fn func(Event)) {
+	r.Handle(Replace, ignoreError(fn))
+}
+
+func (r *Root) OnTruncate(fn func(Event)) {
+	r.Handle(Truncate, ignoreError(fn))
+}
+
+func (r *Root) OnLink(fn func(Event)) {
+	r.Handle(Link, ignoreError(fn))
+}
+
+func (r *Root) OnRelink(fn func(Event)) {
+	r.Handle(Relink, ignoreError(fn))
+}
+
+func (r *Root) OnCreated(fn func(Event)) {
+	r.Handle(Created, ignoreError(fn))
+}
+
+func (r *Root) OnWriteProgress(fn func(Event)) {
+	r.Handle(WriteProgress, ignoreError(fn))
+}
+
+func (r *Root) OnWriteStalled(fn func(Event)) {
+	r.Handle(WriteStalled, ignoreError(fn))
+}
+
+// SetHandlerWorkers sets number of goroutines running handlers.
+// call before Watch().
+func (r *Root) SetHandlerWorkers(n int) error {
+	return r.handlers.setWorkers(n)
+}
+
+func ignoreError(fn func(Event)) HandlerFunc {
+	return func(e Event) error {
+		fn(e)
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package dirnotify
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHandle(t *testing.T) {
+	r := newTestEventRoot()
+	defer r.handlers.stop()
+
+	created := make(chan Event, 1)
+
+	r.OnCreate(func(e Event) {
+		created <- e
+	})
+	r.Handle(Remove, func(e Event) error {
+		return errors.New("remove failed")
+	})
+	r.OnMove(func(e Event) {
+		panic("move panic")
+	})
+
+	r.send(Event{op: Create, path: "/tmp/foo"})
+	r.send(Event{op: Remove, path: "/tmp/foo"})
+	r.send(Event{op: Move, path: "/tmp/bar", beforePath: "/tmp/foo"})
+
+	select {
+	case e := <-created:
+		if e.Path() != "/tmp/foo" {
+			t.Fatalf("[TestHandle] event is different: %s", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestHandle] OnCreate handler is not called.")
+	}
+
+	// handler error & panic
+	for i := 0; i < 2; i++ {
+		select {
+		case <-r.Errors:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("[TestHandle] handler error is not reported.")
+		}
+	}
+
+	if err := r.SetHandlerWorkers(4); err == nil {
+		t.Fatalf("[TestHandle] SetHandlerWorkers succeeded after start.")
+	}
+}
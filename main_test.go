@@ -33,6 +33,7 @@ func TestNonWatch(t *testing.T) {
 	// prepare
 	makeTempDir()
 	createTestNodeTree(t)
+	drainWatcher(_root)
 
 	SubTestRootFindDir(t)
 	SubTestManipulateFile(t)
@@ -67,6 +68,40 @@ func createTestNodeTree(t *testing.T) {
 	_root = r
 }
 
+// read watcher events without Watch().
+// watcher.Remove waits until removed event is read.
+func drainWatcher(r *Root) {
+	events, errs := r.watcher.Events, r.watcher.Errors
+
+	go func() {
+		for events != nil || errs != nil {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					events = nil
+				}
+			case _, ok := <-errs:
+				if !ok {
+					errs = nil
+				}
+			}
+		}
+	}()
+}
+
+// Root without node tree & watcher for event delivery tests.
+func newTestEventRoot() *Root {
+	return &Root{
+		followed:    map[devIno]*Node{},
+		Errors:      make(chan error, errorsBufferSize),
+		subscribers: newSubscribers(),
+		handlers:    newHandlers(),
+		checksums:   newChecksums(),
+		status:      &status{},
+		done:        make(chan struct{}),
+	}
+}
+
 func makeTempDir() {
 	_dirs[0] = tempdir()
 
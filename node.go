@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -26,10 +27,30 @@ import (
  */
 
 type Node struct {
-	info   *fileinfo.FileInfo
-	parent *Node            // parent directory
-	dirs   map[string]*Node // directory(has directories or files)
-	files  map[string]*Node // file(end node)
+	id          uint64 // correlation ID, assigned on Root.addNode
+	info        *fileinfo.FileInfo
+	sys         *sysInfo   // dev, uid, gid, nlink, ctime
+	link        *linkInfo  // symbolic link (info is nil when not followed)
+	writePrev   *nodeState // state before write (until WriteComplete)
+	closed      *nodeState // state on WriteComplete by close write
+	lastChange  time.Time  // last write or change (until WriteComplete)
+	writeClosed bool       // closed after last write (until WriteComplete)
+	firstWrite  time.Time  // first write (until WriteComplete)
+	progress    *nodeState // state on last WriteProgress
+	progressAt  time.Time
+	stalled     bool             // incomplete and unchanged for timeout
+	parent      *Node            // parent directory
+	dirs        map[string]*Node // directory(has directories or files)
+	files       map[string]*Node // file(end node)
+}
+
+// snapshot of node attributes for comparing.
+type nodeState struct {
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+	uid     uint32
+	gid     uint32
 }
 
 func NewChildNode(parent *Node, childName string) *Node {
@@ -39,8 +60,11 @@ func NewChildNode(parent *Node, childName string) *Node {
 
 	absPath := parent.Path() + fileinfo.PathSep + childName
 
-	fi, err := fileinfo.Stat(absPath)
-	if err != nil {
+	n := &Node{
+		parent: parent,
+	}
+
+	if err := n.load(absPath); err != nil {
 		if debug {
 			log.Printf("[NewChildNode] fileinfo error: %s\n", absPath)
 		}
@@ -48,14 +72,9 @@ func NewChildNode(parent *Node, childName string) *Node {
 		return nil
 	}
 
-	n := &Node{
-		info:   fi,
-		parent: parent,
-	}
-
 	// add parent dirs or files
 	// watcher add on directory
-	if fi.IsDir() {
+	if n.IsDir() {
 		n.dirs = map[string]*Node{}
 		n.files = map[string]*Node{}
 
@@ -70,6 +89,10 @@ func NewChildNode(parent *Node, childName string) *Node {
 func (n Node) String() string {
 	result := fmt.Sprintf("info: [%s]", n.info)
 
+	if n.link != nil {
+		result += fmt.Sprintf(", link: [Path: %s, Target: %s]", n.Path(), n.link.target)
+	}
+
 	if len(n.dirs) == 0 && len(n.files) == 0 {
 		return result
 	}
@@ -103,45 +126,242 @@ func (n *Node) FileInfo() *fileinfo.FileInfo {
 	return n.info
 }
 
+// update fileinfo with system dependent attributes of same file.
+func (n *Node) setInfo(fi *fileinfo.FileInfo, sys *sysInfo) {
+	n.info = fi
+	n.sys = sys
+}
+
+// stat absPath without following symbolic link.
+// followed link is kept when target is not changed.
+func (n *Node) load(absPath string) error {
+	lfi, ino, sys, err := lstatSys(absPath)
+	if err != nil {
+		return err
+	}
+
+	link, err := newLinkInfo(absPath, lfi, ino, sys)
+	if err != nil {
+		return err
+	}
+
+	if link == nil {
+		// sysInfo of lstat is used for not symbolic link.
+		fi, sys, err := sameStatInfo(absPath, lstatSys, ino, sys)
+		if err != nil {
+			return err
+		}
+
+		n.link = nil
+		n.setInfo(fi, sys)
+
+		return nil
+	}
+
+	if n.link != nil && n.link.followed && n.link.target == link.target {
+		if fi, sys, err := statInfo(absPath); err == nil && (fi.IsDir() || n.link.inRoot != "") {
+			link.followed = true
+			link.inRoot = n.link.inRoot
+			n.link = link
+			n.setInfo(fi, sys)
+
+			return nil
+		}
+	}
+
+	n.link = link
+	n.info = nil
+	n.sys = link.sys
+
+	return nil
+}
+
 func (n *Node) Name() string {
+	if n.info == nil {
+		return n.link.fi.Name()
+	}
+
 	return n.info.Name()
 }
 
 func (n *Node) Size() int64 {
+	if n.info == nil {
+		return n.link.fi.Size()
+	}
+
 	return n.info.Size()
 }
 
 func (n *Node) ModTime() time.Time {
+	if n.info == nil {
+		return n.link.fi.ModTime()
+	}
+
 	return n.info.ModTime()
 }
 
+// false on link to directory in root. (not expanded)
 func (n *Node) IsDir() bool {
+	if n.info == nil || (n.link != nil && n.link.inRoot != "") {
+		return false
+	}
+
 	return n.info.IsDir()
 }
 
 func (n *Node) Dir() string {
+	if n.info == nil {
+		return n.link.dir
+	}
+
 	return n.info.Dir()
 }
 
 func (n *Node) Path() string {
-	return n.info.Path()
+	return n.Dir() + fileinfo.PathSep + n.Name()
 }
 
+// inode of link itself when symbolic link.
 func (n *Node) Ino() uint64 {
+	if n.link != nil {
+		return n.link.ino
+	}
+
 	return n.info.Ino()
 }
 
-func (n *Node) Stat() error {
-	fi, err := fileinfo.Stat(n.Path())
-	if err != nil {
-		return nil
+func (n *Node) Mode() os.FileMode {
+	if n.info == nil {
+		return n.link.fi.Mode()
 	}
 
-	n.info = fi
+	return n.info.Mode()
+}
+
+// device of link itself when symbolic link.
+func (n *Node) Dev() uint64 {
+	if n.link != nil && n.link.sys != nil {
+		return n.link.sys.dev
+	}
+
+	if n.sys == nil {
+		return 0
+	}
+
+	return n.sys.dev
+}
+
+// CorrelationID is same on all events of Node, kept across rename.
+func (n *Node) CorrelationID() uint64 {
+	return n.id
+}
+
+// link destination. empty when not symbolic link.
+func (n *Node) LinkTarget() string {
+	if n.link == nil {
+		return ""
+	}
+
+	return n.link.target
+}
+
+// device and inode of node itself. (key of NodeMap)
+func (n *Node) key() devIno {
+	return devIno{dev: n.Dev(), ino: n.Ino()}
+}
+
+// device and inode of directory (link target when followed).
+func (n *Node) dirKey() devIno {
+	if n.info == nil || n.sys == nil {
+		return devIno{}
+	}
+
+	return devIno{dev: n.sys.dev, ino: n.info.Ino()}
+}
+
+func (n *Node) Uid() uint32 {
+	if n.sys == nil {
+		return 0
+	}
+
+	return n.sys.uid
+}
+
+func (n *Node) Gid() uint32 {
+	if n.sys == nil {
+		return 0
+	}
+
+	return n.sys.gid
+}
+
+func (n *Node) Nlink() uint64 {
+	if n.sys == nil {
+		return 0
+	}
+
+	return n.sys.nlink
+}
+
+func (n *Node) Ctime() time.Time {
+	if n.sys == nil {
+		return time.Time{}
+	}
+
+	return n.sys.ctime
+}
+
+// error when file is removed.
+func (n *Node) Stat() error {
+	if err := n.load(n.Path()); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+func (n *Node) state() *nodeState {
+	return &nodeState{
+		size:    n.Size(),
+		modTime: n.ModTime(),
+		mode:    n.Mode(),
+		uid:     n.Uid(),
+		gid:     n.Gid(),
+	}
+}
+
+// reset write tracking on WriteComplete or WriteStalled.
+// return state before write.
+func (n *Node) endWrite() *nodeState {
+	prev := n.writePrev
+
+	n.writePrev = nil
+	n.lastChange = time.Time{}
+	n.writeClosed = false
+	n.firstWrite = time.Time{}
+	n.progress = nil
+	n.stalled = false
+
+	return prev
+}
+
+// write tracking of old is moved to n. (e.g. node tree is rebuilt)
+func (n *Node) takeWrite(old *Node) {
+	n.writePrev = old.writePrev
+	n.closed = old.closed
+	n.lastChange = old.lastChange
+	n.writeClosed = old.writeClosed
+	n.firstWrite = old.firstWrite
+	n.progress = old.progress
+	n.progressAt = old.progressAt
+	n.stalled = old.stalled
+}
+
+// compare attributes except size.
+func (ns *nodeState) attribChanged(n *Node) bool {
+	return !ns.modTime.Equal(n.ModTime()) || ns.mode != n.Mode() || ns.uid != n.Uid() || ns.gid != n.Gid()
+}
+
 // 2nd return bool
 // true: target.
 // false: non target.
@@ -174,11 +394,12 @@ func (n *Node) rename(name string, parent *Node) (dirNodes []*Node, oldDirs []st
 	if n.IsDir() {
 		oldDirs = append(oldDirs, n.Path())
 
-		if _, ok := n.parent.dirs[curName]; ok {
+		// other node may be created on same name.
+		if dir, ok := n.parent.dirs[curName]; ok && dir == n {
 			delete(n.parent.dirs, curName)
 		}
 	} else {
-		if _, ok := n.parent.files[curName]; ok {
+		if file, ok := n.parent.files[curName]; ok && file == n {
 			delete(n.parent.files, curName)
 		}
 	}
@@ -190,13 +411,10 @@ func (n *Node) rename(name string, parent *Node) (dirNodes []*Node, oldDirs []st
 	// update fileinfo
 	absPath := n.parent.Path() + fileinfo.PathSep + name
 
-	fi, err := fileinfo.Stat(absPath)
-	if err != nil {
+	if err = n.load(absPath); err != nil {
 		return
 	}
 
-	n.info = fi
-
 	// add parents
 	if n.IsDir() {
 		dirNodes = append(dirNodes, n)
@@ -219,13 +437,10 @@ func (n *Node) updateInfo() ([]*Node, []string, error) {
 
 	absPath := n.parent.Path() + fileinfo.PathSep + n.Name()
 
-	fi, err := fileinfo.Stat(absPath)
-	if err != nil {
+	if err := n.load(absPath); err != nil {
 		return nil, nil, err
 	}
 
-	n.info = fi
-
 	if nodes, dirs, err := n.updateChildren(); err == nil {
 		if n.IsDir() {
 			nodes = append(nodes, n)
@@ -262,32 +477,18 @@ func (n *Node) remove() (removeNodes []*Node, err error) {
 		return nil, errors.New("Node remove error: parent is nil.")
 	}
 
-	if _, ok := n.parent.dirs[n.Name()]; ok {
+	// other node may be created on same name.
+	if dir, ok := n.parent.dirs[n.Name()]; ok && dir == n {
 		delete(n.parent.dirs, n.Name())
 	}
 
-	if _, ok := n.parent.files[n.Name()]; ok {
+	if file, ok := n.parent.files[n.Name()]; ok && file == n {
 		delete(n.parent.files, n.Name())
 	}
 
+	// children are kept in removed node. (e.g. keep IDs on Move)
 	removeNodes = append(removeNodes, n)
-
-	for _, file := range n.files {
-		if nodes, err := file.remove(); err == nil {
-			removeNodes = append(removeNodes, nodes...)
-		} else {
-			return removeNodes, err
-		}
-	}
-
-	// recursive call
-	for _, dir := range n.dirs {
-		if nodes, err := dir.remove(); err == nil {
-			removeNodes = append(removeNodes, nodes...)
-		} else {
-			return removeNodes, err
-		}
-	}
+	removeNodes = append(removeNodes, n.children()...)
 
 	return
 }
@@ -307,6 +508,23 @@ func (n *Node) children() []*Node {
 	return nodes
 }
 
+// keep correlation IDs of removed node on moved node. (recursive call)
+func (n *Node) keepIDs(old *Node) {
+	n.id = old.id
+
+	for name, file := range n.files {
+		if o, ok := old.files[name]; ok && o.key() == file.key() {
+			file.keepIDs(o)
+		}
+	}
+
+	for name, dir := range n.dirs {
+		if o, ok := old.dirs[name]; ok && o.key() == dir.key() {
+			dir.keepIDs(o)
+		}
+	}
+}
+
 // debug
 func (n *Node) PrintTree() string {
 	return n.printTree(0)
@@ -332,6 +550,11 @@ func (n *Node) printTree(depth int) string {
 
 func (n *Node) walk(fn walkFunc) error {
 	if n.info == nil {
+		if n.link != nil {
+			// not followed symbolic link
+			return nil
+		}
+
 		return errors.New("[Node/walk] error: node info is nil.")
 	}
 
@@ -430,6 +653,10 @@ func (n *Node) checkDirectory() (eventQueues, error) {
 		if file, ok := removeFiles[name]; ok {
 			files[name] = file
 			delete(removeFiles, name)
+		} else if dir, ok := removeDirs[name]; ok && dir.link != nil {
+			// followed symbolic link
+			dirs[name] = dir
+			delete(removeDirs, name)
 		} else {
 			nfFiles = append(nfFiles, name)
 		}
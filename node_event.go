@@ -3,6 +3,7 @@ package dirnotify
 import (
 	"errors"
 	"fmt"
+	"time"
 	// third party
 	"github.com/satom9to5/fileinfo"
 )
@@ -13,6 +14,26 @@ type nodeEvent struct {
 	Op
 	node       *Node
 	beforePath string
+	observedAt time.Time
+	prev       *nodeState // before change
+
+	// overwritten node (Replace)
+	replacedIno  uint64
+	replacedPath string
+	replacedPrev *nodeState // state of overwritten node
+	replacedID   uint64     // correlation ID of overwritten node
+
+	// target of overwritten symbolic link (Relink)
+	prevLinkTarget string
+
+	// other hard links (Link)
+	links []string
+
+	// node path before rename on Create
+	renamedFrom string
+
+	// removed node is not paired with Create (Remove)
+	unlinked bool
 }
 
 func (ne nodeEvent) String() string {
@@ -85,8 +106,53 @@ func (ne *nodeEvent) FileInfo() *fileinfo.FileInfo {
 	}
 }
 
+// symbolic link is replaced by other symbolic link.
+func (ne *nodeEvent) relinked() bool {
+	return ne.replacedIno != 0 && ne.prevLinkTarget != "" && ne.node != nil && ne.node.link != nil
+}
+
+// check Create event is pair of Remove/Rename event on p.
+func (ne *nodeEvent) pairWith(p string) bool {
+	return ne.renamedFrom == "" || ne.renamedFrom == p
+}
+
+func (ne *nodeEvent) samePath(other *nodeEvent) bool {
+	p, err := ne.Path()
+	if err != nil {
+		return false
+	}
+
+	op, err := other.Path()
+	if err != nil {
+		return false
+	}
+
+	return p == op
+}
+
+// remove existing node which is overwritten on p.
+// node renamed to other path is not overwritten.
+// removed node is overwritten only when symbolic link is created again. (Relink)
+func (ne *nodeEvent) replace(r *Root, p string, key devIno, eqs *eventQueues) error {
+	old, err := r.Find(p)
+	if err != nil || (old.IsDir() && old.link == nil) || eqs.has(Rename, p) {
+		return nil
+	}
+	if old.key() == key && !eqs.has(Remove, p) {
+		return nil
+	}
+
+	ne.replacedIno = old.Ino()
+	ne.replacedPath = old.Path()
+	ne.replacedPrev = old.state()
+	ne.replacedID = old.id
+	ne.prevLinkTarget = old.LinkTarget()
+
+	return r.removeNode(old)
+}
+
 func (ne *nodeEvent) checkWritableEvent() error {
-	if ne.Op&Remove == Remove || ne.Op&Chmod == Chmod {
+	if ne.Op&Remove == Remove || ne.Op&Chmod == Chmod || ne.Op&Attrib == Attrib {
 		return errors.New("[nodeEvent/checkWritableevent] error: event type is not writable.")
 	}
 
@@ -31,61 +31,71 @@ func SubTestWatch(t *testing.T) {
 	var testPatterns []watchTestPattern
 	var err error
 
-	doneCh := make(chan bool)
+	doneCh := make(chan error)
 
-	writeFile := func(p string, size int) {
+	writeFile := func(p string, size int) error {
 		fp, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 		if err != nil {
-			t.Fatalf("[SubTestWatch] failed to open file: %s", err)
+			return errors.New(fmt.Sprintf("failed to open file: %s", err))
 		}
 		defer fp.Close()
 
 		writer := bufio.NewWriter(fp)
 		// append header size
 		if _, err := writer.WriteString(makeTestDummyString(size + 4096)); err != nil {
-			t.Fatalf("[SubTestWatch] failed to write file: %s", err)
+			return errors.New(fmt.Sprintf("failed to write file: %s", err))
 		}
+
+		return writer.Flush()
 	}
 
-	// emulate user manipulate function
-	manipulate := func(manipulations []watchTestManipulation) {
-		for _, m := range manipulations {
-			absPath := filepath.Join(_dirs[0], filepath.FromSlash(m.dir), m.name)
-
-			switch m.Op {
-			case Create:
-				if m.isDir {
-					if err = os.MkdirAll(absPath, 0777); err != nil {
-						t.Fatalf("[SubTestWatch] failed to create directory: %s", err)
-					}
-				} else {
-					if m.size > 0 {
-						writeFile(absPath, m.size)
-					} else {
-						if f, err := os.Create(absPath); err != nil {
-							t.Fatalf("[SubTestWatch] failed to create file: %s", err)
-						} else {
-							f.Close()
-						}
-					}
+	manipulateOne := func(m watchTestManipulation) error {
+		absPath := filepath.Join(_dirs[0], filepath.FromSlash(m.dir), m.name)
+
+		switch m.Op {
+		case Create:
+			if m.isDir {
+				if err := os.MkdirAll(absPath, 0777); err != nil {
+					return errors.New(fmt.Sprintf("failed to create directory: %s", err))
+				}
+			} else if m.size > 0 {
+				return writeFile(absPath, m.size)
+			} else {
+				f, err := os.Create(absPath)
+				if err != nil {
+					return errors.New(fmt.Sprintf("failed to create file: %s", err))
 				}
-			case Rename:
-				renamedPath := filepath.Join(_dirs[0], filepath.FromSlash(m.toDir), m.toName)
-				if err = os.Rename(absPath, renamedPath); err != nil {
-					t.Fatalf("[SubTestWatch] failed to rename: %s", err)
+				f.Close()
+			}
+		case Rename:
+			renamedPath := filepath.Join(_dirs[0], filepath.FromSlash(m.toDir), m.toName)
+			if err := os.Rename(absPath, renamedPath); err != nil {
+				return errors.New(fmt.Sprintf("failed to rename: %s", err))
+			}
+		case Remove:
+			if m.isDir {
+				if err := os.RemoveAll(absPath); err != nil {
+					return errors.New(fmt.Sprintf("failed to remove directory: %s", err))
 				}
-			case Remove:
-				if m.isDir {
-					if err = os.RemoveAll(absPath); err != nil {
-						t.Fatalf("[SubTestWatch] failed to remove directory: %s", err)
-					}
-				} else {
-					if err = os.Remove(absPath); err != nil {
-						t.Fatalf("[SubTestWatch] failed to remove file: %s", err)
-					}
+			} else {
+				if err := os.Remove(absPath); err != nil {
+					return errors.New(fmt.Sprintf("failed to remove file: %s", err))
 				}
-			case Write:
-				writeFile(absPath, m.size)
+			}
+		case Write:
+			return writeFile(absPath, m.size)
+		}
+
+		return nil
+	}
+
+	// emulate user manipulate function
+	// error is sent to doneCh because t.Fatalf is not allowed on goroutine.
+	manipulate := func(manipulations []watchTestManipulation) {
+		for _, m := range manipulations {
+			if err := manipulateOne(m); err != nil {
+				doneCh <- err
+				return
 			}
 		}
 
@@ -93,32 +103,35 @@ func SubTestWatch(t *testing.T) {
 		for {
 			time.Sleep(2 * time.Second)
 
-			if len(*(_root.queues)) == 0 && len(*(_root.writeNodes)) == 0 {
+			_root.mu.Lock()
+			waiting := len(*(_root.queues)) + len(*(_root.writeNodes))
+			_root.mu.Unlock()
+
+			if waiting == 0 {
 				break
 			}
 		}
 
-		doneCh <- true
+		doneCh <- nil
 	}
 
 	// wait send events function
-	eventWatch := func() []Event {
+	// event may be sent after manipulate finished, so wait until expected length.
+	eventWatch := func(length int) []Event {
 		events := []Event{}
 
 		finished := false
-		// create new file
-		//for !finished {
-		for !finished {
+		for !finished || len(events) < length {
 			select {
 			case e := <-_root.Ch:
 				events = append(events, e)
-			case <-time.After(3 * time.Second):
-				t.Fatalf("[SubTestWatch] too long to wait for event.")
-			case <-doneCh:
-				if len(*(_root.queues)) == 0 {
-					finished = true
-					break
+			case <-time.After(5 * time.Second):
+				t.Fatalf("[SubTestWatch] too long to wait for event. events: %v", events)
+			case err := <-doneCh:
+				if err != nil {
+					t.Fatalf("[SubTestWatch] %s", err)
 				}
+				finished = true
 			}
 		}
 
@@ -132,8 +145,6 @@ func SubTestWatch(t *testing.T) {
 		{Create, false, "opt/etc/httpd", "httpd.conf", "", "", 8192}, // until watcher.Add parent directory
 	})
 
-	events = eventWatch()
-
 	testPatterns = []watchTestPattern{
 		{Create, "opt", ""},
 		{Create, "opt/etc", ""},
@@ -143,6 +154,8 @@ func SubTestWatch(t *testing.T) {
 		{WriteComplete, "opt/etc/httpd/httpd.conf", ""},
 	}
 
+	events = eventWatch(len(testPatterns))
+
 	if err = testEventLength(events, testPatterns); err != nil {
 		t.Fatalf("[SubTestWatch] event length is different: %s", err)
 	}
@@ -165,8 +178,6 @@ func SubTestWatch(t *testing.T) {
 		{Create, false, "opt/etc/", "resolve.conf", "", "", 8192},
 	})
 
-	events = eventWatch()
-
 	testPatterns = []watchTestPattern{
 		{Move, "usr/etc", "usr/local/etc"},
 		{Create, "opt/etc/resolve.conf", ""},
@@ -174,10 +185,12 @@ func SubTestWatch(t *testing.T) {
 		{Remove, "usr/bin/cat.exe", ""},
 		{Move, "usr/local/bin/ls.exe", "usr/bin/ls.exe"},
 		{Remove, "opt/etc/httpd/httpd.conf", ""},
-		{Move, "usr/local/bin/less.exe", "usr/local/bin/more.exe"},
+		{Rename, "usr/local/bin/less.exe", "usr/local/bin/more.exe"},
 		{WriteComplete, "opt/etc/resolve.conf", ""},
 	}
 
+	events = eventWatch(len(testPatterns))
+
 	if err = testEventLength(events, testPatterns); err != nil {
 		t.Fatalf("[SubTestWatch] event length is different: %s", err)
 	}
@@ -198,8 +211,6 @@ func SubTestWatch(t *testing.T) {
 		{Write, false, "usr/bin", "grep.exe", "", "", 1024000},
 	})
 
-	events = eventWatch()
-
 	testPatterns = []watchTestPattern{
 		{Create, "usr/bin/grep.exe", ""},
 		{Create, "usr/etc/hosts.conf", ""},
@@ -207,6 +218,8 @@ func SubTestWatch(t *testing.T) {
 		{WriteComplete, "usr/local/bin/ls.exe", ""},
 	}
 
+	events = eventWatch(len(testPatterns))
+
 	if err = testEventLength(events, testPatterns); err != nil {
 		t.Fatalf("[SubTestWatch] event length is different: %s", err)
 	}
@@ -219,7 +232,7 @@ func SubTestWatch(t *testing.T) {
 		}
 	}
 
-	testNodes(t, _root.node)
+	testNodes(t, _root.root)
 }
 
 func testEventLength(events []Event, patterns []watchTestPattern) error {
@@ -3,6 +3,7 @@ package dirnotify
 import (
 	"errors"
 	"log"
+	"os"
 	// third party
 	"github.com/satom9to5/fileinfo"
 )
@@ -11,59 +12,121 @@ type nodeEvents []nodeEvent
 
 func (nes *nodeEvents) add(eq eventQueue, eqs *eventQueues, r *Root) error {
 	ne := nodeEvent{
-		Op: eq.Op,
+		Op:         eq.Op,
+		observedAt: eq.observedAt,
 	}
 	var node *Node
 	var err error
 
 	switch true {
 	case eq.Op&Create == Create:
-		fi, err := fileinfo.Stat(eq.Path())
-		if err != nil {
+		// symbolic link is not followed.
+		fi, ino, sys, err := lstatSys(eq.Path())
+		if r.atomicSave && os.IsNotExist(err) {
+			// removed before (e.g. temp file of atomic save)
+			return nil
+		} else if err != nil {
 			return err
 		}
 
-		if node = r.InoFind(fi.Ino()); node != nil {
+		// removed and created again on same path.
+		// Remove is sent before Create except symbolic link replaced by other link (Relink).
+		if old, err := r.Find(eq.Path()); err == nil && !(old.link != nil && fi.Mode()&os.ModeSymlink != 0) {
+			if removed, ok := eqs.take(Remove, eq.Path()); ok {
+				if err = nes.add(removed, eqs, r); err != nil {
+					return err
+				}
+			}
+		}
+
+		key := statKey(ino, sys)
+
+		// overwritten file on same path
+		if err = ne.replace(r, eq.Path(), key, eqs); err != nil {
+			return err
+		}
+
+		// other hard links of same inode
+		linked := r.liveLinks(key, eqs)
+
+		if node = r.findRenamed(eq.Path(), key, eqs); node != nil {
 			// when same inode found
+			ne.prev = node.state()
+			ne.renamedFrom = node.Path()
 
 			// rename dir of eventQueues
-			eqs.rename(node.Path(), fi.Path())
+			eqs.rename(node.Path(), eq.Path())
 
 			// rename nodes
-			if err = r.renameNode(node, fi.Dir(), fi.Name()); err != nil {
+			if err = r.renameNode(node, eq.dir, eq.base); err != nil {
 				return err
 			}
 		} else {
 			// add root
-			node, err = r.createAddNode(fi.Path())
+			node, err = r.createAddNode(eq.Path())
 			if err != nil {
 				return err
 			}
+			// renamed from temp file not in node tree
+			if r.atomicSave && ne.replacedIno != 0 {
+				ne.beforePath = eqs.findTempRename(eq.Path())
+			}
+			// directory link
+			if err = r.followLink(node); err != nil {
+				return err
+			}
 			// append children dirs/files
 			// example case is create directories on os.mkdirAll
 			if node.IsDir() {
 				if err = r.appendNodes(node); err != nil {
 					return err
 				}
+				if err = r.followLinks(node); err != nil {
+					return err
+				}
 			}
 			// append eventQueues
 			eqs.addFromNodes(node.children())
+
+			// new hard link
+			if len(linked) > 0 && !node.IsDir() {
+				ne.Op = Link
+				for _, link := range linked {
+					ne.links = append(ne.links, link.Path())
+				}
+			}
 		}
 
 		ne.node = node
 	case eq.Op&Remove == Remove:
 		if eq.node == nil {
+			// created and removed before (e.g. temp file of atomic save)
+			if _, err := os.Lstat(eq.Path()); r.atomicSave && os.IsNotExist(err) {
+				return nil
+			}
+
 			return errors.New("[events/add] Remove error: Node is nil.")
 		}
 
+		// already removed on Replace
+		if nes.replaced(eq.node) {
+			return nil
+		}
+
 		// set remove node info
 		ne.node = eq.node
+		ne.unlinked = eq.unlinked
 		// remove node
 		if eq.Path() == eq.node.Path() {
 			r.removeNode(eq.node)
 		}
 	case eq.Op&Rename == Rename:
 		if eq.node == nil {
+			// created and renamed before (e.g. temp file of atomic save)
+			if _, err := os.Lstat(eq.Path()); r.atomicSave && os.IsNotExist(err) {
+				return nil
+			}
+
 			return errors.New("[events/add] Rename error: Node is nil.")
 		}
 
@@ -79,15 +142,29 @@ func (nes *nodeEvents) add(eq eventQueue, eqs *eventQueues, r *Root) error {
 			r.removeNode(eq.node)
 		}
 	case eq.Op&Write == Write:
+		// node added on Create event before
+		if eq.node == nil {
+			eq.node, _ = r.Find(eq.Path())
+		}
+
 		if eq.node != nil {
+			// already completed on close write
+			if !eq.node.writtenAfterClose() {
+				return nil
+			}
+
 			ne.node = eq.node
+			ne.prev = eq.node.state()
 			r.appendWriteNodes(ne)
 			return nil
 		}
 
 		// add node when nonexist
 		fi, err := fileinfo.Stat(eq.Path())
-		if err != nil {
+		if r.atomicSave && os.IsNotExist(err) {
+			// removed before (e.g. temp file of atomic save)
+			return nil
+		} else if err != nil {
 			return err
 		}
 
@@ -100,11 +177,32 @@ func (nes *nodeEvents) add(eq eventQueue, eqs *eventQueues, r *Root) error {
 		// rewrite Event Type
 		ne.Op = Create
 	case eq.Op&Chmod == Chmod:
-		return nil
+		if eq.node == nil {
+			return nil
+		}
+
+		// compare stored fileinfo with current.
+		prev := eq.node.state()
+		if err = eq.node.Stat(); os.IsNotExist(err) {
+			// removed before (Remove is sent)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !prev.attribChanged(eq.node) {
+			return nil
+		}
+
+		ne.node = eq.node
+		ne.prev = prev
+
+		// rewrite Event Type
+		ne.Op = Attrib
 	}
 
 	// find same inode event.
-	targetEvent, targetIndex, err := nes.findByFileInfo(ne.FileInfo())
+	targetEvent, targetIndex, err := nes.findByNode(ne.node)
 	if err != nil {
 		log.Println("[NodeEvent/add] not found fileInfo: " + ne.String())
 		return err
@@ -115,51 +213,81 @@ func (nes *nodeEvents) add(eq eventQueue, eqs *eventQueues, r *Root) error {
 		return nil
 	}
 
+	targetPath, err := targetEvent.Path()
+	if err != nil {
+		return err
+	}
+
 	// merge same inode event. (pattern is Move only.)
 	// Move Pattern: Create + Remove or Create + Rename
+	// not paired on same path or unlinked node. (inode may be reused)
 	switch true {
+	case targetPath == eq.Path(), ne.unlinked, targetEvent.unlinked:
 	case targetEvent.Op&Create == Create:
 		switch true {
+		case !targetEvent.pairWith(eq.Path()):
+			// event on other hard link
 		case ne.Op&Remove == Remove, ne.Op&Rename == Rename:
 			ne.beforePath = eq.Path()
 			ne.node = targetEvent.node
+			ne.prev = targetEvent.prev
+			ne.replacedIno = targetEvent.replacedIno
+			ne.replacedPath = targetEvent.replacedPath
+			ne.replacedPrev = targetEvent.replacedPrev
+			ne.replacedID = targetEvent.replacedID
+			ne.prevLinkTarget = targetEvent.prevLinkTarget
 			ne.Op |= targetEvent.Op
 		}
 	case targetEvent.Op&Remove == Remove, targetEvent.Op&Rename == Rename:
 		switch true {
+		case !ne.pairWith(targetPath):
+			// event on other hard link
 		case ne.Op&Create == Create:
-			beforePath, err := targetEvent.Path()
-			if err != nil {
-				return err
-			}
-			ne.beforePath = beforePath
+			ne.beforePath = targetPath
 			ne.Op |= targetEvent.Op
+
+			// node is created again after removed node.
+			if ne.node != targetEvent.node {
+				ne.node.keepIDs(targetEvent.node)
+			}
+
+			// state of removed node.
+			if ne.prev == nil {
+				ne.prev = targetEvent.node.state()
+			}
 		}
 	}
 
 	if ne.beforePath != "" {
+		// keep first observed time.
+		if targetEvent.observedAt.Before(ne.observedAt) {
+			ne.observedAt = targetEvent.observedAt
+		}
+
 		(*nes)[targetIndex] = ne
+	} else if !ne.samePath(targetEvent) || ne.node != targetEvent.node {
+		// event on other hard link or new node with reused inode
+		*nes = append(*nes, ne)
 	}
 
 	return nil
 }
 
-func (nes *nodeEvents) findByFileInfo(fi *fileinfo.FileInfo) (*nodeEvent, int, error) {
-	if fi == nil {
+func (nes *nodeEvents) findByNode(n *Node) (*nodeEvent, int, error) {
+	if n == nil {
 		return nil, -1, nil
 	}
 
-	ino := fi.Ino()
+	key := n.key()
 	targetIndex := -1
 
 	// find same inode event.
 	for index, e := range *nes {
-		eino, err := e.Ino()
-		if err != nil {
+		if _, err := e.Ino(); err != nil {
 			return nil, -1, err
 		}
 
-		if ino == eino {
+		if key == e.node.key() {
 			targetIndex = index
 			break
 		}
@@ -172,10 +300,49 @@ func (nes *nodeEvents) findByFileInfo(fi *fileinfo.FileInfo) (*nodeEvent, int, e
 	}
 }
 
+// check n is removed by Replace event.
+func (nes *nodeEvents) replaced(n *Node) bool {
+	for _, ne := range *nes {
+		if ne.replacedIno == n.Ino() && ne.replacedPath == n.Path() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rename: same parent directory, Move: different parent directory.
+// Remove: moved out of root. (Rename without Create)
+// Replace: existing file is overwritten.
+// Relink: symbolic link is overwritten by other symbolic link.
 func (nes *nodeEvents) updateOp() {
 	for i, ne := range *nes {
+		if ne.Op == Rename {
+			ne.Op = Remove
+			(*nes)[i] = ne
+			continue
+		}
+
+		if ne.relinked() {
+			ne.Op = Relink
+			(*nes)[i] = ne
+			continue
+		}
+
+		if ne.replacedIno != 0 {
+			ne.Op = Replace
+			(*nes)[i] = ne
+			continue
+		}
+
 		if ne.Op&Create == Create && ne.Op&(Remove|Rename) > 0 {
-			ne.Op = Move
+			beforeDir, _ := fileinfo.Split(ne.beforePath)
+
+			if dir, err := ne.Dir(); err == nil && dir == beforeDir {
+				ne.Op = Rename
+			} else {
+				ne.Op = Move
+			}
 			(*nes)[i] = ne
 		}
 	}
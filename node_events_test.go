@@ -0,0 +1,697 @@
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	// third party
+	"github.com/satom9to5/fsnotify"
+)
+
+// Root on new temp directory with files.
+func createTestFileTree(t *testing.T, files ...string) (*Root, string) {
+	dir := tempdir()
+
+	for _, file := range files {
+		p := filepath.Join(dir, filepath.FromSlash(file))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatalf("[createTestFileTree] failed to create directory: %s", err)
+		}
+
+		if f, err := os.Create(p); err != nil {
+			t.Fatalf("[createTestFileTree] failed to create file: %s", err)
+		} else {
+			f.Close()
+		}
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[createTestFileTree] cannot create Root: %s", err)
+	}
+
+	return r, dir
+}
+
+func TestNodeEventsAttrib(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/ls.exe")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	p := filepath.Join(dir, "usr", "bin", "ls.exe")
+
+	if err := os.Chmod(p, 0600); err != nil {
+		t.Fatalf("[TestNodeEventsAttrib] failed to chmod: %s", err)
+	}
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsAttrib] failed to Root/Find: %s", err)
+	}
+	prevMode := node.Mode()
+
+	eqs := &eventQueues{}
+	eqs.addFromNode(node, Chmod)
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsAttrib] failed to createNodeEvents: %s", err)
+	}
+
+	if len(*nes) != 1 {
+		t.Fatalf("[TestNodeEventsAttrib] event length is different. expect: 1, fact: %d", len(*nes))
+	}
+
+	e := newEvent((*nes)[0])
+	if e.Op() != Attrib || e.PrevMode() != prevMode || e.Mode().Perm() != 0600 {
+		t.Fatalf("[TestNodeEventsAttrib] event is different: %s, prev mode: %s", e, e.PrevMode())
+	}
+
+	// no change
+	eqs.addFromNode(node, Chmod)
+
+	if nes, err = eqs.createNodeEvents(r); err != nil || len(*nes) != 0 {
+		t.Fatalf("[TestNodeEventsAttrib] Attrib is sent without change: %v", err)
+	}
+}
+
+func TestNodeEventsWritePrev(t *testing.T) {
+	r, dir := createTestFileTree(t, "var/log/access.log")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 1})
+	defer cancel()
+
+	p := filepath.Join(dir, "var", "log", "access.log")
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsWritePrev] failed to Root/Find: %s", err)
+	}
+
+	if err = ioutil.WriteFile(p, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("[TestNodeEventsWritePrev] failed to write file: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.addFromNode(node, Write)
+
+	if _, err = eqs.createNodeEvents(r); err != nil {
+		t.Fatalf("[TestNodeEventsWritePrev] failed to createNodeEvents: %s", err)
+	}
+
+	// 1st: size updated, 2nd: unchanged
+	r.checkWriteNodes()
+	r.checkWriteNodes()
+
+	select {
+	case e := <-ch:
+		if e.Op() != WriteComplete || e.PrevSize() != 0 || e.Size() != 100 {
+			t.Fatalf("[TestNodeEventsWritePrev] event is different: %s, prev size: %d", e, e.PrevSize())
+		}
+	default:
+		t.Fatalf("[TestNodeEventsWritePrev] WriteComplete is not sent.")
+	}
+}
+
+func TestNodeEventsWriteRemoved(t *testing.T) {
+	r, dir := createTestFileTree(t, "var/log/access.log")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 1})
+	defer cancel()
+
+	p := filepath.Join(dir, "var", "log", "access.log")
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsWriteRemoved] failed to Root/Find: %s", err)
+	}
+
+	if err = ioutil.WriteFile(p, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("[TestNodeEventsWriteRemoved] failed to write file: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.addFromNode(node, Write)
+
+	if _, err = eqs.createNodeEvents(r); err != nil {
+		t.Fatalf("[TestNodeEventsWriteRemoved] failed to createNodeEvents: %s", err)
+	}
+
+	// size updated
+	r.checkWriteNodes()
+
+	if err = os.Remove(p); err != nil {
+		t.Fatalf("[TestNodeEventsWriteRemoved] failed to remove file: %s", err)
+	}
+
+	r.checkWriteNodes()
+
+	if len(*(r.writeNodes)) != 0 {
+		t.Fatalf("[TestNodeEventsWriteRemoved] removed file remains in writeNodes.")
+	}
+
+	select {
+	case e := <-ch:
+		t.Fatalf("[TestNodeEventsWriteRemoved] event is sent for removed file: %s", e)
+	default:
+	}
+}
+
+func TestNodeEventsRenameMove(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/more.exe", "usr/bin/ls.exe", "usr/local/bin/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	patterns := []struct {
+		Op
+		from string
+		to   string
+	}{
+		{Rename, "usr/bin/more.exe", "usr/bin/less.exe"},
+		{Move, "usr/bin/ls.exe", "usr/local/bin/ls.exe"},
+	}
+
+	for _, pattern := range patterns {
+		from := filepath.Join(dir, filepath.FromSlash(pattern.from))
+		to := filepath.Join(dir, filepath.FromSlash(pattern.to))
+
+		if err := os.Rename(from, to); err != nil {
+			t.Fatalf("[TestNodeEventsRenameMove] failed to rename: %s", err)
+		}
+
+		eqs := &eventQueues{}
+		eqs.add(fsnotify.Event{Name: from, Op: fsnotify.Rename}, r)
+		eqs.add(fsnotify.Event{Name: to, Op: fsnotify.Create}, r)
+		eqs.sort()
+
+		nes, err := eqs.createNodeEvents(r)
+		if err != nil {
+			t.Fatalf("[TestNodeEventsRenameMove] failed to createNodeEvents: %s", err)
+		}
+
+		if len(*nes) != 1 {
+			t.Fatalf("[TestNodeEventsRenameMove] event length is different. expect: 1, fact: %d", len(*nes))
+		}
+
+		e := newEvent((*nes)[0])
+		if e.Op() != pattern.Op || e.Path() != to || e.BeforePath() != from {
+			t.Fatalf("[TestNodeEventsRenameMove] event is different: %s", e)
+		}
+	}
+
+	// moved out of root
+	ext := tempdir()
+	defer os.RemoveAll(ext)
+
+	from := filepath.Join(dir, "usr", "bin", "less.exe")
+	if err := os.Rename(from, filepath.Join(ext, "less.exe")); err != nil {
+		t.Fatalf("[TestNodeEventsRenameMove] failed to rename: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.add(fsnotify.Event{Name: from, Op: fsnotify.Rename}, r)
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil || len(*nes) != 1 {
+		t.Fatalf("[TestNodeEventsRenameMove] failed to createNodeEvents: %v", err)
+	}
+
+	if e := newEvent((*nes)[0]); e.Op() != Remove || e.Path() != from || e.BeforePath() != "" {
+		t.Fatalf("[TestNodeEventsRenameMove] moved out event is different: %s", e)
+	}
+	if _, err := r.Find(from); err == nil {
+		t.Fatalf("[TestNodeEventsRenameMove] moved out node remains.")
+	}
+}
+
+func TestNodeEventsReplace(t *testing.T) {
+	r, dir := createTestFileTree(t, "etc/new.cfg", "etc/app.cfg")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	from := filepath.Join(dir, "etc", "new.cfg")
+	to := filepath.Join(dir, "etc", "app.cfg")
+
+	newNode, _ := r.Find(from)
+	oldNode, _ := r.Find(to)
+	if newNode == nil || oldNode == nil {
+		t.Fatalf("[TestNodeEventsReplace] failed to Root/Find.")
+	}
+	newIno, oldIno := newNode.Ino(), oldNode.Ino()
+
+	if err := os.Rename(from, to); err != nil {
+		t.Fatalf("[TestNodeEventsReplace] failed to rename: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.add(fsnotify.Event{Name: from, Op: fsnotify.Rename}, r)
+	eqs.add(fsnotify.Event{Name: to, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsReplace] failed to createNodeEvents: %s", err)
+	}
+
+	if len(*nes) != 1 {
+		t.Fatalf("[TestNodeEventsReplace] event length is different. expect: 1, fact: %d", len(*nes))
+	}
+
+	e := newEvent((*nes)[0])
+	if e.Op() != Replace || e.Path() != to || e.BeforePath() != from ||
+		e.Ino() != newIno || e.ReplacedIno() != oldIno || e.ReplacedPath() != to {
+		t.Fatalf("[TestNodeEventsReplace] event is different: %s, replaced: %d %s", e, e.ReplacedIno(), e.ReplacedPath())
+	}
+
+	if r.InoFind(oldIno) != nil {
+		t.Fatalf("[TestNodeEventsReplace] replaced node remains in nodeMap.")
+	}
+	if node, err := r.Find(to); err != nil || node.Ino() != newIno {
+		t.Fatalf("[TestNodeEventsReplace] failed to Root/Find replaced path: %v", err)
+	}
+}
+
+func TestNodeEventsRemoveCreate(t *testing.T) {
+	r, dir := createTestFileTree(t, "etc/app.cfg", "etc/hosts")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	patterns := []struct {
+		name   string
+		op     fsnotify.Op
+		before string
+		expect []Op
+	}{
+		{"app.cfg", fsnotify.Remove, "", []Op{Remove, Create}},
+		{"hosts", fsnotify.Rename, "hosts.bak", []Op{Create, Rename}},
+	}
+
+	for _, pattern := range patterns {
+		p := filepath.Join(dir, "etc", pattern.name)
+
+		// keep inode of old file not to be reused.
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatalf("[TestNodeEventsRemoveCreate] failed to open file: %s", err)
+		}
+		defer f.Close()
+
+		if pattern.before != "" {
+			err = os.Rename(p, filepath.Join(dir, "etc", pattern.before))
+		} else {
+			err = os.Remove(p)
+		}
+		if err != nil {
+			t.Fatalf("[TestNodeEventsRemoveCreate] failed to remove: %s", err)
+		}
+
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatalf("[TestNodeEventsRemoveCreate] failed to create file: %s", err)
+		}
+
+		eqs := &eventQueues{}
+		eqs.add(fsnotify.Event{Name: p, Op: pattern.op}, r)
+		if pattern.before != "" {
+			eqs.add(fsnotify.Event{Name: filepath.Join(dir, "etc", pattern.before), Op: fsnotify.Create}, r)
+		}
+		eqs.add(fsnotify.Event{Name: p, Op: fsnotify.Create}, r)
+		eqs.sort()
+
+		nes, err := eqs.createNodeEvents(r)
+		if err != nil {
+			t.Fatalf("[TestNodeEventsRemoveCreate] failed to createNodeEvents: %s", err)
+		}
+
+		if len(*nes) != len(pattern.expect) {
+			t.Fatalf("[TestNodeEventsRemoveCreate] %s event length is different. expect: %d, fact: %d", pattern.name, len(pattern.expect), len(*nes))
+		}
+
+		for i, op := range pattern.expect {
+			if e := newEvent((*nes)[i]); e.Op() != op {
+				t.Fatalf("[TestNodeEventsRemoveCreate] %s event is different. expect: %s, fact: %s", pattern.name, op, e)
+			}
+		}
+
+		if node, err := r.Find(p); err != nil || node.Ino() == 0 {
+			t.Fatalf("[TestNodeEventsRemoveCreate] failed to Root/Find created path: %v", err)
+		}
+	}
+
+	// created again with same inode. (e.g. inode reused)
+	p := filepath.Join(dir, "etc", "app.cfg")
+	tmp := filepath.Join(dir, "app.cfg.tmp")
+
+	for _, fn := range []func() error{
+		func() error { return os.Link(p, tmp) },
+		func() error { return os.Remove(p) },
+		func() error { return os.Link(tmp, p) },
+		func() error { return os.Remove(tmp) },
+	} {
+		if err := fn(); err != nil {
+			t.Fatalf("[TestNodeEventsRemoveCreate] failed to relink file: %s", err)
+		}
+	}
+
+	eqs := &eventQueues{}
+	eqs.add(fsnotify.Event{Name: p, Op: fsnotify.Remove}, r)
+	eqs.add(fsnotify.Event{Name: p, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil || len(*nes) != 2 {
+		t.Fatalf("[TestNodeEventsRemoveCreate] failed to createNodeEvents: %v", err)
+	}
+
+	if e := newEvent((*nes)[0]); e.Op() != Remove || e.Path() != p {
+		t.Fatalf("[TestNodeEventsRemoveCreate] event is different. expect: Remove, fact: %s", e)
+	}
+	if e := newEvent((*nes)[1]); e.Op() != Create || e.Path() != p || e.BeforePath() != "" {
+		t.Fatalf("[TestNodeEventsRemoveCreate] event is different. expect: Create, fact: %s", e)
+	}
+}
+
+func TestNodeEventsInodeReused(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/cat.exe", "opt/etc/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	removed := filepath.Join(dir, "usr", "bin", "cat.exe")
+	created := filepath.Join(dir, "opt", "etc", "resolve.conf")
+
+	// new file has inode of removed file.
+	if err := os.Link(removed, created); err != nil {
+		t.Fatalf("[TestNodeEventsInodeReused] failed to link: %s", err)
+	}
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("[TestNodeEventsInodeReused] failed to remove: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.add(fsnotify.Event{Name: removed, Op: fsnotify.Remove}, r)
+	eqs.add(fsnotify.Event{Name: created, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil || len(*nes) != 2 {
+		t.Fatalf("[TestNodeEventsInodeReused] failed to createNodeEvents: %v", err)
+	}
+
+	for _, ne := range *nes {
+		if e := newEvent(ne); e.Op() != Create && e.Op() != Remove || e.BeforePath() != "" {
+			t.Fatalf("[TestNodeEventsInodeReused] removed file is paired: %s", e)
+		}
+	}
+
+	if node, err := r.Find(created); err != nil || len(r.LinkFind(node.Ino())) != 1 {
+		t.Fatalf("[TestNodeEventsInodeReused] failed to Root/Find created path: %v", err)
+	}
+}
+
+func TestNodeEventsTruncate(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(p, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("[TestNodeEventsTruncate] failed to write file: %s", err)
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestNodeEventsTruncate] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 2})
+	defer cancel()
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsTruncate] failed to Root/Find: %s", err)
+	}
+
+	if err = os.Truncate(p, 10); err != nil {
+		t.Fatalf("[TestNodeEventsTruncate] failed to truncate: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.addFromNode(node, Write)
+
+	if _, err = eqs.createNodeEvents(r); err != nil {
+		t.Fatalf("[TestNodeEventsTruncate] failed to createNodeEvents: %s", err)
+	}
+
+	r.checkWriteNodes()
+	r.checkWriteNodes()
+
+	patterns := []struct {
+		Op
+		prevSize int64
+		size     int64
+	}{
+		{Truncate, 100, 10},
+		{WriteComplete, 10, 10},
+	}
+
+	for _, pattern := range patterns {
+		select {
+		case e := <-ch:
+			if e.Op() != pattern.Op || e.PrevSize() != pattern.prevSize || e.Size() != pattern.size {
+				t.Fatalf("[TestNodeEventsTruncate] event is different: %s, prev size: %d", e, e.PrevSize())
+			}
+		default:
+			t.Fatalf("[TestNodeEventsTruncate] %s is not sent.", pattern.Op)
+		}
+	}
+}
+
+func TestNodeEventsLink(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/vi.exe")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	vi := filepath.Join(dir, "usr", "bin", "vi.exe")
+	vim := filepath.Join(dir, "usr", "bin", "vim.exe")
+	nvim := filepath.Join(dir, "usr", "bin", "nvim.exe")
+
+	createEvents := func(fsEvents ...fsnotify.Event) []Event {
+		eqs := &eventQueues{}
+		for _, fsEvent := range fsEvents {
+			eqs.add(fsEvent, r)
+		}
+		eqs.sort()
+
+		nes, err := eqs.createNodeEvents(r)
+		if err != nil {
+			t.Fatalf("[TestNodeEventsLink] failed to createNodeEvents: %s", err)
+		}
+
+		events := []Event{}
+		for _, ne := range *nes {
+			events = append(events, newEvent(ne))
+		}
+
+		return events
+	}
+
+	// new hard link
+	if err := os.Link(vi, vim); err != nil {
+		t.Fatalf("[TestNodeEventsLink] failed to link: %s", err)
+	}
+
+	events := createEvents(fsnotify.Event{Name: vim, Op: fsnotify.Create})
+	if len(events) != 1 || events[0].Op() != Link || events[0].Path() != vim ||
+		len(events[0].Links()) != 1 || events[0].Links()[0] != vi {
+		t.Fatalf("[TestNodeEventsLink] Link event is different: %v", events)
+	}
+
+	ino := events[0].Ino()
+	if len(r.LinkFind(ino)) != 2 {
+		t.Fatalf("[TestNodeEventsLink] hard links are not tracked: %d", len(r.LinkFind(ino)))
+	}
+
+	// remove one link & rename other link
+	if err := os.Remove(vi); err != nil {
+		t.Fatalf("[TestNodeEventsLink] failed to remove: %s", err)
+	}
+	if err := os.Rename(vim, nvim); err != nil {
+		t.Fatalf("[TestNodeEventsLink] failed to rename: %s", err)
+	}
+
+	events = createEvents(
+		fsnotify.Event{Name: vi, Op: fsnotify.Remove},
+		fsnotify.Event{Name: vim, Op: fsnotify.Rename},
+		fsnotify.Event{Name: nvim, Op: fsnotify.Create},
+	)
+
+	if len(events) != 2 {
+		t.Fatalf("[TestNodeEventsLink] event length is different. expect: 2, fact: %d, %v", len(events), events)
+	}
+
+	for _, e := range events {
+		switch e.Op() {
+		case Remove:
+			if e.Path() != vi {
+				t.Fatalf("[TestNodeEventsLink] Remove event is different: %s", e)
+			}
+		case Rename:
+			if e.Path() != nvim || e.BeforePath() != vim {
+				t.Fatalf("[TestNodeEventsLink] Rename event is different: %s", e)
+			}
+		default:
+			t.Fatalf("[TestNodeEventsLink] unexpected event: %s", e)
+		}
+	}
+
+	if node := r.InoFind(ino); node == nil || node.Path() != nvim {
+		t.Fatalf("[TestNodeEventsLink] remaining link is not found: %v", node)
+	}
+}
+
+func TestNodeEventsCorrelationID(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/data.bin", "archive/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	from := filepath.Join(dir, "upload", "data.bin")
+	to := filepath.Join(dir, "archive", "data.bin")
+
+	node, err := r.Find(from)
+	if err != nil {
+		t.Fatalf("[TestNodeEventsCorrelationID] failed to Root/Find: %s", err)
+	}
+
+	id := node.CorrelationID()
+	if id == 0 {
+		t.Fatalf("[TestNodeEventsCorrelationID] correlation ID is not assigned.")
+	}
+
+	if other, _ := r.Find(filepath.Join(dir, "archive", ".keep")); other == nil || other.CorrelationID() == id {
+		t.Fatalf("[TestNodeEventsCorrelationID] correlation ID is not unique.")
+	}
+
+	if err = os.Rename(from, to); err != nil {
+		t.Fatalf("[TestNodeEventsCorrelationID] failed to rename: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.add(fsnotify.Event{Name: from, Op: fsnotify.Rename}, r)
+	eqs.add(fsnotify.Event{Name: to, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil || len(*nes) != 1 {
+		t.Fatalf("[TestNodeEventsCorrelationID] failed to createNodeEvents: %v", err)
+	}
+
+	if e := newEvent((*nes)[0]); e.Op() != Move || e.CorrelationID() != id {
+		t.Fatalf("[TestNodeEventsCorrelationID] correlation ID is changed on Move. expect: %d, fact: %d", id, e.CorrelationID())
+	}
+
+	if e := newEventByOpNode(WriteComplete, node); e.CorrelationID() != id {
+		t.Fatalf("[TestNodeEventsCorrelationID] correlation ID is changed on WriteComplete. expect: %d, fact: %d", id, e.CorrelationID())
+	}
+}
+
+func TestNodeEventsCorrelationIDSorted(t *testing.T) {
+	r, dir := createTestFileTree(t, "archive/data.bin", "archive/photos/a.jpg", "upload/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	// watch of moved directory is removed.
+	drainWatcher(r)
+
+	// Rename is processed before Create. (source path sorts first)
+	for _, name := range []string{"data.bin", "photos"} {
+		from := filepath.Join(dir, "archive", name)
+		to := filepath.Join(dir, "upload", name)
+
+		ids := map[string]uint64{}
+		node, err := r.Find(from)
+		if err != nil {
+			t.Fatalf("[TestNodeEventsCorrelationIDSorted] failed to Root/Find: %s", err)
+		}
+		for _, n := range append(node.children(), node) {
+			rel, _ := filepath.Rel(from, n.Path())
+			ids[rel] = n.CorrelationID()
+		}
+
+		if err = os.Rename(from, to); err != nil {
+			t.Fatalf("[TestNodeEventsCorrelationIDSorted] failed to rename: %s", err)
+		}
+
+		eqs := &eventQueues{}
+		eqs.add(fsnotify.Event{Name: from, Op: fsnotify.Rename}, r)
+		eqs.add(fsnotify.Event{Name: to, Op: fsnotify.Create}, r)
+		eqs.sort()
+
+		nes, err := eqs.createNodeEvents(r)
+		if err != nil || len(*nes) == 0 {
+			t.Fatalf("[TestNodeEventsCorrelationIDSorted] failed to createNodeEvents: %v", err)
+		}
+
+		if e := newEvent((*nes)[0]); e.Op() != Move || e.CorrelationID() != ids["."] {
+			t.Fatalf("[TestNodeEventsCorrelationIDSorted] correlation ID is changed on Move. expect: %d, fact: %s(%d)", ids["."], e, e.CorrelationID())
+		}
+
+		// children of moved directory
+		for rel, id := range ids {
+			n, err := r.Find(filepath.Join(to, rel))
+			if err != nil {
+				t.Fatalf("[TestNodeEventsCorrelationIDSorted] failed to Root/Find moved node: %s", err)
+			}
+			if n.CorrelationID() != id {
+				t.Fatalf("[TestNodeEventsCorrelationIDSorted] correlation ID of %s is changed. expect: %d, fact: %d", rel, id, n.CorrelationID())
+			}
+		}
+	}
+}
+
+func TestRebuildCorrelationID(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/data.bin", "archive/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	p := filepath.Join(dir, "upload", "data.bin")
+	link := filepath.Join(dir, "archive", "data.bin")
+
+	if err := os.Link(p, link); err != nil {
+		t.Fatalf("[TestRebuildCorrelationID] failed to link: %s", err)
+	}
+	if err := r.rebuild(); err != nil {
+		t.Fatalf("[TestRebuildCorrelationID] failed to rebuild: %s", err)
+	}
+
+	ids := map[string]uint64{}
+	for _, path := range []string{p, link} {
+		node, err := r.Find(path)
+		if err != nil {
+			t.Fatalf("[TestRebuildCorrelationID] failed to Root/Find: %s", err)
+		}
+		ids[path] = node.CorrelationID()
+	}
+
+	if ids[p] == ids[link] {
+		t.Fatalf("[TestRebuildCorrelationID] correlation ID of hard links is same.")
+	}
+
+	if err := r.rebuild(); err != nil {
+		t.Fatalf("[TestRebuildCorrelationID] failed to rebuild: %s", err)
+	}
+
+	for path, id := range ids {
+		node, err := r.Find(path)
+		if err != nil {
+			t.Fatalf("[TestRebuildCorrelationID] failed to Root/Find: %s", err)
+		}
+		if node.CorrelationID() != id {
+			t.Fatalf("[TestRebuildCorrelationID] correlation ID of %s is changed. expect: %d, fact: %d", path, id, node.CorrelationID())
+		}
+	}
+}
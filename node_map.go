@@ -2,12 +2,59 @@ package dirnotify
 
 import (
 	"errors"
+	"time"
 )
 
-type NodeMap map[uint64]*Node
+// device and inode key. (same inode number on other filesystem is other node)
+type NodeMap map[devIno]*Node
 
-func (nm *NodeMap) get(ino uint64) *Node {
-	if n, ok := (*nm)[ino]; ok {
+// nodes sharing same inode. (hard links)
+type linkMap map[devIno][]*Node
+
+func (lm *linkMap) get(key devIno) []*Node {
+	return (*lm)[key]
+}
+
+// return false when already added (e.g. renamed node).
+func (lm *linkMap) add(n *Node) bool {
+	key := n.key()
+
+	for _, link := range (*lm)[key] {
+		if link == n {
+			return false
+		}
+	}
+
+	(*lm)[key] = append((*lm)[key], n)
+
+	return true
+}
+
+// return remaining links, and false when not added.
+func (lm *linkMap) remove(n *Node) ([]*Node, bool) {
+	key := n.key()
+	links := []*Node{}
+	removed := false
+
+	for _, link := range (*lm)[key] {
+		if link != n {
+			links = append(links, link)
+		} else {
+			removed = true
+		}
+	}
+
+	if len(links) == 0 {
+		delete(*lm, key)
+	} else {
+		(*lm)[key] = links
+	}
+
+	return links, removed
+}
+
+func (nm *NodeMap) get(key devIno) *Node {
+	if n, ok := (*nm)[key]; ok {
 		return n
 	} else {
 		return nil
@@ -15,19 +62,19 @@ func (nm *NodeMap) get(ino uint64) *Node {
 }
 
 func (nm *NodeMap) add(n *Node) error {
-	ino := n.Ino()
-	if ino == 0 {
+	key := n.key()
+	if key.ino == 0 {
 		return errors.New("[NodeMap/add] error: inode is empty.")
 	}
 
-	(*nm)[ino] = n
+	(*nm)[key] = n
 
 	return nil
 }
 
-func (nm *NodeMap) remove(ino uint64) error {
-	if _, ok := (*nm)[ino]; ok {
-		delete(*nm, ino)
+func (nm *NodeMap) remove(key devIno) error {
+	if _, ok := (*nm)[key]; ok {
+		delete(*nm, key)
 
 		return nil
 	} else {
@@ -35,25 +82,42 @@ func (nm *NodeMap) remove(ino uint64) error {
 	}
 }
 
-func (nm *NodeMap) checkWriteComplete() []*Node {
+// complete: decides WriteComplete with current state.
+// 2nd return: Truncate events when size decreased.
+func (nm *NodeMap) checkWriteComplete(complete func(*Node, WriteState) bool) ([]*Node, nodeEvents) {
 	nodes := []*Node{}
+	truncated := nodeEvents{}
 
-	for ino, node := range *nm {
+	for key, node := range *nm {
 		// get current value before update
+		prev := node.state()
 		preTime := node.ModTime()
 		preSize := node.Size()
 
-		if err := node.Stat(); err == nil {
-			if node.ModTime() == preTime && node.Size() == preSize {
-				nodes = append(nodes, node)
+		if err := node.Stat(); err != nil {
+			// when file removed. (WriteComplete is not sent)
+			nm.remove(key)
+			node.endWrite()
+			continue
+		}
 
-				nm.remove(ino)
-			}
-		} else {
-			// when file removed.
-			nm.remove(ino)
+		changed := node.ModTime() != preTime || node.Size() != preSize
+
+		if node.Size() < preSize {
+			truncated = append(truncated, nodeEvent{
+				Op:         Truncate,
+				node:       node,
+				prev:       prev,
+				observedAt: time.Now(),
+			})
+		}
+
+		if complete(node, node.writeState(changed)) {
+			nodes = append(nodes, node)
+
+			nm.remove(key)
 		}
 	}
 
-	return nodes
+	return nodes, truncated
 }
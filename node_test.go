@@ -3,6 +3,9 @@ package dirnotify
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -27,3 +30,37 @@ func testNodes(t *testing.T, node *Node) error {
 
 	return nil
 }
+
+func TestNodeMapDevice(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(p, []byte("file"), 0644); err != nil {
+		t.Fatalf("[TestNodeMapDevice] failed to write file: %s", err)
+	}
+
+	node := &Node{}
+	if err := node.load(p); err != nil {
+		t.Fatalf("[TestNodeMapDevice] failed to load node: %s", err)
+	}
+
+	// same inode number on other filesystem.
+	other := &Node{info: node.info, sys: &sysInfo{dev: node.Dev() + 1}}
+
+	nm := NodeMap{}
+	nm.add(node)
+	nm.add(other)
+
+	if nm.get(node.key()) != node || nm.get(other.key()) != other {
+		t.Fatalf("[TestNodeMapDevice] node of other device is overwritten.")
+	}
+
+	lm := linkMap{}
+	lm.add(node)
+	lm.add(other)
+
+	if links := lm.get(node.key()); len(links) != 1 || links[0] != node {
+		t.Fatalf("[TestNodeMapDevice] node of other device is linked: %d", len(links))
+	}
+}
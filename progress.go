@@ -0,0 +1,73 @@
+package dirnotify
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// SetWriteProgress sends WriteProgress of files in writing every interval.
+// 0 is disabled.
+func (r *Root) SetWriteProgress(interval time.Duration) error {
+	if interval < 0 {
+		return errors.New("[Root/SetWriteProgress] error: interval must be 0 or more.")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.progressEvery = interval
+
+	return nil
+}
+
+// append WriteProgress of nodes in writeNodes.
+// called when Root.mu locked.
+func (r *Root) writeProgress(events []Event) []Event {
+	if r.progressEvery == 0 {
+		return events
+	}
+
+	now := time.Now()
+	nodes := []*Node{}
+
+	for _, node := range *(r.writeNodes) {
+		if node.progress != nil && now.Sub(node.progressAt) >= r.progressEvery {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Path() < nodes[j].Path()
+	})
+
+	for _, node := range nodes {
+		event := newEventByOpNode(WriteProgress, node)
+		event.observedAt = now
+		event.prev = node.writePrev
+		event.bytesPerSecond = writeRate(node.progress.size, node.Size(), now.Sub(node.progressAt))
+		event.writeElapsed = now.Sub(node.firstWrite)
+
+		node.progress = node.state()
+		node.progressAt = now
+
+		if debug {
+			log.Println("[Root/writeProgress] event: " + event.String())
+		}
+
+		// send channel
+		events = r.emit(events, event)
+	}
+
+	return events
+}
+
+// 0 when size decreased. (sent as Truncate)
+func writeRate(prevSize, size int64, d time.Duration) float64 {
+	if size <= prevSize || d <= 0 {
+		return 0
+	}
+
+	return float64(size-prevSize) / d.Seconds()
+}
@@ -0,0 +1,93 @@
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteProgress(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "test.bin")
+	if err := ioutil.WriteFile(p, make([]byte, 10), 0644); err != nil {
+		t.Fatalf("[TestWriteProgress] failed to write file: %s", err)
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestWriteProgress] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 1})
+	defer cancel()
+
+	if err := r.SetWriteProgress(-1); err == nil {
+		t.Fatalf("[TestWriteProgress] negative interval is accepted.")
+	}
+	if err := r.SetWriteProgress(10 * time.Millisecond); err != nil {
+		t.Fatalf("[TestWriteProgress] failed to SetWriteProgress: %s", err)
+	}
+
+	// keep in writeNodes
+	r.SetCompleteness("*.bin", CloseWrite())
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestWriteProgress] cannot find node: %s", err)
+	}
+	r.appendWriteNodes(nodeEvent{Op: Write, node: node})
+
+	time.Sleep(20 * time.Millisecond)
+
+	if err := ioutil.WriteFile(p, make([]byte, 30), 0644); err != nil {
+		t.Fatalf("[TestWriteProgress] failed to write file: %s", err)
+	}
+
+	r.checkWriteNodes()
+
+	select {
+	case e := <-ch:
+		if e.Op() != WriteProgress || e.Size() != 30 || e.PrevSize() != 10 {
+			t.Fatalf("[TestWriteProgress] event is different: %s", e)
+		}
+		if e.BytesPerSecond() <= 0 || e.WriteElapsed() < 20*time.Millisecond {
+			t.Fatalf("[TestWriteProgress] progress is different. rate: %f, elapsed: %s", e.BytesPerSecond(), e.WriteElapsed())
+		}
+	default:
+		t.Fatalf("[TestWriteProgress] WriteProgress is not sent.")
+	}
+
+	// interval is not elapsed.
+	r.checkWriteNodes()
+
+	select {
+	case e := <-ch:
+		t.Fatalf("[TestWriteProgress] WriteProgress is sent before interval: %s", e)
+	default:
+	}
+}
+
+func TestWriteRate(t *testing.T) {
+	patterns := []struct {
+		prevSize int64
+		size     int64
+		d        time.Duration
+		expect   float64
+	}{
+		{0, 100, time.Second, 100},
+		{100, 300, 2 * time.Second, 100},
+		{300, 100, time.Second, 0},
+		{0, 100, 0, 0},
+	}
+
+	for _, pattern := range patterns {
+		if rate := writeRate(pattern.prevSize, pattern.size, pattern.d); rate != pattern.expect {
+			t.Fatalf("[TestWriteRate] rate is different. expect: %f, fact: %f", pattern.expect, rate)
+		}
+	}
+}
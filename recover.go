@@ -0,0 +1,40 @@
+package dirnotify
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+const (
+	defaultMaxRestarts = 5
+	// restart count is reset when watch loop runs longer than this.
+	defaultRestartReset = 10 * time.Minute
+	// wait before retry of failed rebuild.
+	defaultRestartDelay = 1 * time.Second
+)
+
+// PanicError is sent to Root.Errors when panic recovered.
+type PanicError struct {
+	Where string // recovered function
+	Value interface{}
+	Stack []byte
+}
+
+func newPanicError(where string, value interface{}) *PanicError {
+	return &PanicError{
+		Where: where,
+		Value: value,
+		Stack: stack(),
+	}
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("[%s] panic: %v\n%s", pe.Where, pe.Value, pe.Stack)
+}
+
+func stack() []byte {
+	buf := make([]byte, 64*1024)
+
+	return buf[:runtime.Stack(buf, false)]
+}
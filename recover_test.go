@@ -0,0 +1,215 @@
+package dirnotify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchRestart(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "usr", "bin"), 0777); err != nil {
+		t.Fatalf("[TestWatchRestart] failed to create directory: %s", err)
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestWatchRestart] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	if err = r.SetMaxRestarts(1); err != nil {
+		t.Fatalf("[TestWatchRestart] failed to SetMaxRestarts: %s", err)
+	}
+
+	r.Watch()
+
+	waitError := func() error {
+		select {
+		case err := <-r.Errors:
+			return err
+		case <-time.After(3 * time.Second):
+			t.Fatalf("[TestWatchRestart] too long to wait for error.")
+		}
+		return nil
+	}
+
+	// restart
+	r.panics <- newPanicError("TestWatchRestart", "first")
+
+	if _, ok := waitError().(*PanicError); !ok {
+		t.Fatalf("[TestWatchRestart] error is not PanicError.")
+	}
+
+	// wait rebuild
+	waitRestarts(t, r, 1)
+
+	r.mu.Lock()
+	_, err = r.Find(filepath.Join(dir, "usr", "bin"))
+	r.mu.Unlock()
+	if err != nil {
+		t.Fatalf("[TestWatchRestart] failed to Root/Find after rebuild: %s", err)
+	}
+
+	// exceed restart limit
+	r.panics <- newPanicError("TestWatchRestart", "second")
+
+	// changed while watching.
+	if err = r.SetMaxRestarts(1); err != nil {
+		t.Fatalf("[TestWatchRestart] failed to SetMaxRestarts: %s", err)
+	}
+
+	if _, ok := waitError().(*PanicError); !ok {
+		t.Fatalf("[TestWatchRestart] error is not PanicError.")
+	}
+	if _, ok := waitError().(*PanicError); ok {
+		t.Fatalf("[TestWatchRestart] restart limit is not reported.")
+	}
+}
+
+func TestWatchRestartReset(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestWatchRestartReset] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	if err = r.SetMaxRestarts(1); err != nil {
+		t.Fatalf("[TestWatchRestartReset] failed to SetMaxRestarts: %s", err)
+	}
+	// every watch loop is stable.
+	r.restartReset = time.Nanosecond
+
+	r.Watch()
+
+	for i := 1; i <= 3; i++ {
+		r.panics <- newPanicError("TestWatchRestartReset", i)
+
+		waitRestarts(t, r, i)
+	}
+
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("[TestWatchRestartReset] restart limit is applied after stable period: %s", err)
+	}
+}
+
+func TestWatchRestartRetry(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestWatchRestartRetry] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	if err = r.SetMaxRestarts(3); err != nil {
+		t.Fatalf("[TestWatchRestartRetry] failed to SetMaxRestarts: %s", err)
+	}
+	r.restartDelay = 100 * time.Millisecond
+
+	r.Watch()
+
+	// rebuild fails while root is moved.
+	moved := dir + ".moved"
+	if err = os.Rename(dir, moved); err != nil {
+		t.Fatalf("[TestWatchRestartRetry] failed to rename: %s", err)
+	}
+
+	r.panics <- newPanicError("TestWatchRestartRetry", "first")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-r.Errors:
+			if _, ok := err.(*PanicError); ok != (i == 0) {
+				t.Fatalf("[TestWatchRestartRetry] error is different: %s", err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("[TestWatchRestartRetry] too long to wait for error.")
+		}
+	}
+
+	if err = os.Rename(moved, dir); err != nil {
+		t.Fatalf("[TestWatchRestartRetry] failed to rename back: %s", err)
+	}
+
+	// retried rebuild succeeds.
+	waitRestarts(t, r, 1)
+
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("[TestWatchRestartRetry] unhealthy after retry: %s", err)
+	}
+}
+
+func TestWatchRestartPanic(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/ls.exe")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	if err := r.SetMaxRestarts(1); err != nil {
+		t.Fatalf("[TestWatchRestartPanic] failed to SetMaxRestarts: %s", err)
+	}
+
+	r.Watch()
+
+	// node without fileinfo panics on nodeEvents.add of next tick.
+	r.mu.Lock()
+	root := r.root
+	*(r.queues) = append(*(r.queues), eventQueue{Op: Chmod, dir: dir, base: "usr", node: &Node{parent: root}})
+	r.mu.Unlock()
+
+	select {
+	case err := <-r.Errors:
+		if _, ok := err.(*PanicError); !ok {
+			t.Fatalf("[TestWatchRestartPanic] error is not PanicError: %s", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestWatchRestartPanic] panic is not recovered.")
+	}
+
+	waitRestarts(t, r, 1)
+
+	// Root.mu is released on panic.
+	locked := make(chan struct{})
+	go func() {
+		r.mu.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestWatchRestartPanic] Root.mu is not released after panic.")
+	}
+
+	_, err := r.Find(filepath.Join(dir, "usr", "bin", "ls.exe"))
+	rebuilt := r.root != root && len(*(r.queues)) == 0
+	r.mu.Unlock()
+
+	if err != nil || !rebuilt {
+		t.Fatalf("[TestWatchRestartPanic] node tree is not rebuilt: %v", err)
+	}
+
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("[TestWatchRestartPanic] unhealthy after restart: %s", err)
+	}
+}
+
+// wait until watch loop is restarted n times.
+func waitRestarts(t *testing.T, r *Root, n int) {
+	timeout := time.After(3 * time.Second)
+
+	for r.Status().Restarts < n {
+		select {
+		case <-timeout:
+			t.Fatalf("[waitRestarts] too long to wait for restart. expect: %d, fact: %d", n, r.Status().Restarts)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -5,29 +5,69 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 	// third party
 	"github.com/satom9to5/fileinfo"
 	"github.com/satom9to5/fsnotify"
 )
 
+const (
+	errorsBufferSize = 64
+)
+
 type Root struct {
-	root       *Node        // root node
-	nodeMap    *NodeMap     // inode key
-	queues     *eventQueues // event queue
-	writeNodes *NodeMap     // nodes for check write event
-	watcher    *fsnotify.Watcher
-	Ch         chan Event
-	ticker     *time.Ticker
-	chkTicker  *time.Ticker // for check directory
-	mu         sync.Mutex
-	wg         sync.WaitGroup
+	seq           uint64             // last Event sequence number (first for 64bit atomic alignment)
+	batchSeq      uint64             // last Batch ID
+	nodeSeq       uint64             // last correlation ID of Node
+	root          *Node              // root node
+	nodeMap       *NodeMap           // inode key
+	links         *linkMap           // inode key, all hard links
+	followed      map[devIno]*Node   // followed symbolic links, target key
+	linkTargets   map[string][]*Node // followed symbolic links in root, target path
+	queues        *eventQueues       // event queue
+	writeNodes    *NodeMap           // nodes for check write event
+	watcher       *fsnotify.Watcher
+	Ch            chan Event // used when no subscribers (including batch) and handlers
+	Errors        chan error // dropped when buffer is full
+	subscribers   *subscribers
+	handlers      *handlers
+	checksums     *checksums
+	ticker        *time.Ticker
+	chkTicker     *time.Ticker    // for check directory
+	panics        chan error      // recovered panic on addQueue
+	watched       map[string]bool // watcher added directories
+	nodes         int             // nodes in tree (hard links are counted)
+	dirs          int
+	status        *status
+	restarts      int // restarts since watch loop was stable
+	maxRestarts   int
+	restartReset  time.Duration // stable period to reset restarts
+	restartDelay  time.Duration // wait before retry of failed rebuild
+	symlinkPolicy SymlinkPolicy
+	batch         batchEvents // events of next Batch
+	atomicSave    bool        // temp file renamed over target is Write
+	debouncer     *debouncer
+	closeWatcher  *closeWatcher        // IN_CLOSE_WRITE (Linux only)
+	closed        chan string          // closed file path after write
+	rebuilds      chan chan error      // rebuild requested while watching
+	stopped       chan struct{}        // closed when watching is stopped
+	done          chan struct{}        // closed on Close not to wait blocked consumers
+	closes        map[string]time.Time // closed files waiting write event
+	completeness  []completenessRule   // WriteComplete strategy per pattern
+	emptyComplete bool                 // WriteComplete of empty files
+	progressEvery time.Duration        // WriteProgress interval (0 is disabled)
+	stallTimeout  time.Duration        // WriteStalled timeout (0 is disabled)
+	opened        map[string]bool      // files in writing opened by some process (last scan)
+	mu            sync.Mutex
+	wg            sync.WaitGroup
 }
 
 func NewRoot(dirs []string) (*Root, error) {
 	dir := dirs[0] // temporary
-	fi, err := fileinfo.Stat(dir)
+	fi, sys, err := statInfo(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -39,22 +79,42 @@ func NewRoot(dirs []string) (*Root, error) {
 
 	// root node
 	rn := &Node{
-		info:  fi,
 		dirs:  map[string]*Node{},
 		files: map[string]*Node{},
 	}
+	rn.setInfo(fi, sys)
 
 	r := &Root{
-		root:       rn,
-		nodeMap:    &NodeMap{},
-		queues:     &eventQueues{},
-		writeNodes: &NodeMap{},
-		watcher:    watcher,
-		Ch:         make(chan Event),
-	}
+		root:         rn,
+		nodeMap:      &NodeMap{},
+		links:        &linkMap{},
+		followed:     map[devIno]*Node{},
+		linkTargets:  map[string][]*Node{},
+		queues:       &eventQueues{},
+		writeNodes:   &NodeMap{},
+		watcher:      watcher,
+		Ch:           make(chan Event),
+		Errors:       make(chan error, errorsBufferSize),
+		subscribers:  newSubscribers(),
+		handlers:     newHandlers(),
+		checksums:    newChecksums(),
+		panics:       make(chan error, 1),
+		closed:       make(chan string, closedBufferSize),
+		rebuilds:     make(chan chan error),
+		done:         make(chan struct{}),
+		closes:       map[string]time.Time{},
+		watched:      map[string]bool{},
+		maxRestarts:  defaultMaxRestarts,
+		restartReset: defaultRestartReset,
+		restartDelay: defaultRestartDelay,
+		status:       &status{},
+	}
+
+	r.status.Path = rn.Path()
 
 	// watcher add
 	r.addNode(rn)
+	r.updateStatus()
 
 	return r, nil
 }
@@ -69,6 +129,12 @@ func CreateNodeTree(dirs []string) (*Root, error) {
 		return nil, err
 	}
 
+	if err := r.followLinks(r.root); err != nil {
+		return nil, err
+	}
+
+	r.updateStatus()
+
 	return r, nil
 }
 
@@ -81,8 +147,17 @@ func (r *Root) appendNodes(n *Node) error {
 	}
 
 	for _, fi := range fis {
-		chn, err := r.createAddNode(n.Path() + fileinfo.PathSep + fi.Name())
+		p := n.Path() + fileinfo.PathSep + fi.Name()
+
+		chn, err := r.createAddNode(p)
 		if err != nil {
+			// removed after ReadDir (e.g. while rebuild)
+			if vanished(p) {
+				if chn != nil {
+					r.removeNode(chn)
+				}
+				continue
+			}
 			return err
 		}
 
@@ -91,6 +166,10 @@ func (r *Root) appendNodes(n *Node) error {
 		}
 
 		if err := r.appendNodes(chn); err != nil {
+			if vanished(p) {
+				r.removeNode(chn)
+				continue
+			}
 			return err
 		}
 	}
@@ -98,6 +177,13 @@ func (r *Root) appendNodes(n *Node) error {
 	return nil
 }
 
+// p is not exist. symbolic link is not followed.
+func vanished(p string) bool {
+	_, err := os.Lstat(p)
+
+	return os.IsNotExist(err)
+}
+
 func (r *Root) PrintTree() string {
 	return r.root.PrintTree()
 }
@@ -107,6 +193,29 @@ func (r *Root) Close() {
 	if r.watcher != nil {
 		r.watcher.Close()
 	}
+
+	// watch loop may wait consumers with Root.mu locked.
+	if r.done != nil {
+		select {
+		case <-r.done:
+		default:
+			close(r.done)
+		}
+	}
+
+	r.mu.Lock()
+	if r.closeWatcher != nil {
+		r.closeWatcher.close()
+		r.closeWatcher = nil
+	}
+	r.mu.Unlock()
+
+	// send held events before subscribers are removed.
+	r.SetDebounce(0)
+
+	r.subscribers.removeAll()
+	r.handlers.stop()
+	r.checksums.stop()
 }
 
 type walkFunc func(fi fileinfo.FileInfo) error
@@ -120,20 +229,66 @@ func (r *Root) addNode(n *Node) error {
 		return err
 	}
 
+	// kept on rename
+	if n.id == 0 {
+		n.id = atomic.AddUint64(&r.nodeSeq, 1)
+	}
+	if r.links.add(n) {
+		r.nodes++
+		if n.IsDir() {
+			r.dirs++
+		}
+	}
+
 	// watcher add when directory
 	if n.IsDir() {
-		if err := r.watcher.Add(n.Path()); err != nil {
-			if debug {
-				log.Printf("[Root/RenameNode] watcher Add path: %s, error: %s\n", n.Path(), err)
-			}
+		return r.addWatch(n)
+	}
 
-			return err
+	return nil
+}
+
+func (r *Root) addWatch(n *Node) error {
+	if err := r.watcher.Add(n.Path()); err != nil {
+		if debug {
+			log.Printf("[Root/RenameNode] watcher Add path: %s, error: %s\n", n.Path(), err)
+		}
+
+		return err
+	}
+
+	r.watched[n.Path()] = true
+
+	if r.closeWatcher != nil {
+		if err := r.closeWatcher.add(n.Path()); err != nil && debug {
+			log.Printf("[Root/addWatch] close watcher Add path: %s, error: %s\n", n.Path(), err)
 		}
 	}
 
 	return nil
 }
 
+func (r *Root) removeWatch(p string) {
+	// already removed. (e.g. Remove of directory and its parent)
+	if !r.watched[p] {
+		return
+	}
+
+	// watch is removed by kernel when not exist.
+	delete(r.watched, p)
+
+	// ignore not exist diretory error.
+	if err := r.watcher.Remove(p); err != nil {
+		if debug {
+			log.Printf("[Root/removeWatch] watcher Remove path: %s, error: %s\n", p, err)
+		}
+	}
+
+	if r.closeWatcher != nil {
+		r.closeWatcher.remove(p)
+	}
+}
+
 func (r *Root) createAddNode(p string) (*Node, error) {
 	paths := fileinfo.SplitPath(p, r.root.Dir())
 	if len(paths) == 0 {
@@ -181,12 +336,7 @@ func (r *Root) renameNode(n *Node, dir, name string) error {
 
 	// remove from wacher when directory
 	for _, dir := range dirs {
-		// ignore not exist diretory error.
-		if err := r.watcher.Remove(dir); err != nil {
-			if debug {
-				log.Printf("[Root/RenameNode] watcher Remove path: %s, error: %s\n", dir, err)
-			}
-		}
+		r.removeWatch(dir)
 	}
 
 	// add new watcher directory
@@ -212,18 +362,40 @@ func (r *Root) removeNode(n *Node) error {
 	}
 
 	for _, node := range nodes {
-		ino := node.Ino()
-		// ignore cannot find error
-		r.nodeMap.remove(ino)
+		key := node.key()
+
+		links, removed := r.links.remove(node)
+		if removed {
+			r.nodes--
+			if node.IsDir() {
+				r.dirs--
+			}
+		}
+
+		if len(links) > 0 {
+			// keep other hard link
+			r.nodeMap.add(links[0])
+			if r.writeNodes.get(key) == node {
+				r.writeNodes.add(links[0])
+			}
+		} else {
+			// inode may be reused by other node.
+			if r.nodeMap.get(key) == node {
+				r.nodeMap.remove(key)
+			}
+			if r.writeNodes.get(key) == node {
+				r.writeNodes.remove(key)
+			}
+		}
+
+		// links in root are removed from linkTargets on targetLinks.
+		if node.link != nil && node.link.followed && node.link.inRoot == "" {
+			delete(r.followed, node.dirKey())
+		}
 
 		// remove from wacher when directory
 		if node.IsDir() {
-			// ignore not exist diretory error.
-			if err := r.watcher.Remove(node.Path()); err != nil {
-				if debug {
-					log.Printf("[Root/RemoveNode] watcher Remove path: %s, error: %s\n", node.Path(), err)
-				}
-			}
+			r.removeWatch(node.Path())
 		}
 	}
 
@@ -245,12 +417,70 @@ func (r *Root) Find(absPath string) (*Node, error) {
 	return n, nil
 }
 
+// InoFind returns node of inode on device of root directory.
 func (r *Root) InoFind(ino uint64) *Node {
 	if ino == 0 {
 		return nil
 	}
 
-	return r.nodeMap.get(ino)
+	return r.nodeMap.get(devIno{dev: r.root.Dev(), ino: ino})
+}
+
+// LinkFind returns all hard links of inode on device of root directory.
+func (r *Root) LinkFind(ino uint64) []*Node {
+	if ino == 0 {
+		return nil
+	}
+
+	return r.links.get(devIno{dev: r.root.Dev(), ino: ino})
+}
+
+// find node renamed to p.
+// nil when p is new file or new hard link.
+func (r *Root) findRenamed(p string, key devIno, eqs *eventQueues) *Node {
+	links := r.liveLinks(key, eqs)
+
+	// already renamed
+	for _, link := range links {
+		if link.Path() == p {
+			return link
+		}
+	}
+
+	// links which path is not exist.
+	stales := []*Node{}
+	for _, link := range links {
+		if lkey, err := lstatKey(link.Path()); err != nil || lkey != key {
+			stales = append(stales, link)
+		}
+	}
+
+	// prefer link which has Rename event.
+	for _, link := range stales {
+		if eqs.has(Rename, link.Path()) {
+			return link
+		}
+	}
+
+	if len(stales) > 0 {
+		return stales[0]
+	}
+
+	return nil
+}
+
+// hard links of inode except unlinked nodes.
+// inode of unlinked node may be reused by new file.
+func (r *Root) liveLinks(key devIno, eqs *eventQueues) []*Node {
+	links := []*Node{}
+
+	for _, link := range r.links.get(key) {
+		if !eqs.unlinked(link.Path()) {
+			links = append(links, link)
+		}
+	}
+
+	return links
 }
 
 func (r *Root) appendWriteNodes(ne nodeEvent) error {
@@ -262,6 +492,22 @@ func (r *Root) appendWriteNodes(ne nodeEvent) error {
 		return err
 	}
 
+	// keep state on first write.
+	if r.writeNodes.get(ne.node.key()) == nil {
+		if ne.prev != nil {
+			ne.node.writePrev = ne.prev
+		} else {
+			ne.node.writePrev = ne.node.state()
+		}
+
+		ne.node.firstWrite = time.Now()
+		ne.node.progress = ne.node.writePrev
+		ne.node.progressAt = ne.node.firstWrite
+	}
+
+	ne.node.lastChange = time.Now()
+	ne.node.writeClosed = false
+
 	if err := r.writeNodes.add(ne.node); err != nil {
 		return err
 	}
@@ -269,39 +515,68 @@ func (r *Root) appendWriteNodes(ne nodeEvent) error {
 	return nil
 }
 
+// send error without blocking.
+func (r *Root) sendError(err error) {
+	if debug {
+		log.Println(err)
+	}
+
+	r.status.update(func(st *Status) {
+		st.Errors++
+	})
+
+	select {
+	case r.Errors <- err:
+	default:
+	}
+}
+
 // under called in Watch()
 
-func (r *Root) addQueue(e fsnotify.Event) error {
+// observedAt: received time on watch loop. (before waiting Root.mu)
+func (r *Root) addQueue(e fsnotify.Event, observedAt time.Time) error {
 	r.wg.Add(1)
 
 	go func() {
+		defer r.wg.Done()
+
+		// restart on watch loop.
+		defer func() {
+			if p := recover(); p != nil {
+				select {
+				case r.panics <- newPanicError("Root/addQueue", p):
+				default:
+				}
+			}
+		}()
+
 		r.mu.Lock()
+		defer r.mu.Unlock()
 
 		if debug && e.Op > 0 {
 			log.Println("[Root/addQueue] Events: " + e.Op.String() + " Name: " + e.Name)
 		}
 
 		// add queue
-		r.queues.add(e, r)
-
-		r.mu.Unlock()
-
-		r.wg.Done()
+		r.queues.addObserved(e, observedAt, r)
+		r.updateQueueStatus()
 	}()
 
 	return nil
 }
 
 func (r *Root) queuesToEvent() {
+	// wait addQueue before reading queues.
+	r.wg.Wait()
+
 	if len(*(r.queues)) == 0 {
 		return
 	}
 
-	r.wg.Wait()
-
 	// exec goroutine only 1.
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	defer r.updateStatus()
 
 	// check executing on other goroutine.
 	if len(*(r.queues)) == 0 {
@@ -313,13 +588,13 @@ func (r *Root) queuesToEvent() {
 
 	nodeEvents, err := r.queues.createNodeEvents(r)
 	if err != nil {
-		if debug {
-			log.Println(err)
-		}
+		r.sendError(err)
 
 		return
 	}
 
+	events := []Event{}
+
 	for _, ne := range *nodeEvents {
 		event := newEvent(ne)
 		if debug {
@@ -330,45 +605,240 @@ func (r *Root) queuesToEvent() {
 		r.appendWriteNodes(ne)
 
 		// send channel
-		r.Ch <- event
+		events = r.emit(events, event)
 	}
+
+	r.addBatch(events)
 }
 
 func (r *Root) checkWriteNodes() {
+	// used on checkClosed of same tick.
+	r.scanOpened()
+
 	if len(*(r.writeNodes)) == 0 {
 		return
 	}
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	defer r.updateStatus()
 
-	nodes := r.writeNodes.checkWriteComplete()
+	nodes, truncated := r.writeNodes.checkWriteComplete(r.isComplete)
 
-	if len(nodes) == 0 {
-		return
+	events := []Event{}
+
+	for _, ne := range truncated {
+		event := newEvent(ne)
+
+		if debug {
+			log.Println("[Root/checkWriteNodes] event: " + event.String())
+		}
+
+		// WriteComplete reports size after truncated.
+		ne.node.writePrev = ne.node.state()
+
+		// send channel
+		events = r.emit(events, event)
 	}
 
+	// nodes still writing
+	events = r.writeStalled(events)
+	events = r.writeProgress(events)
+
 	for _, node := range nodes {
-		if node.Size() > 0 {
-			event := newEventByOpNode(WriteComplete, node)
+		events = r.completeWrite(events, node)
+	}
 
-			if debug {
-				log.Println("[Root/checkWriteNodes] event: " + event.String())
-			}
+	r.addBatch(events)
+}
 
-			// send channel
-			r.Ch <- event
-		}
+// append WriteComplete of node removed from writeNodes.
+// called when Root.mu locked.
+func (r *Root) completeWrite(events []Event, node *Node) []Event {
+	prev := node.endWrite()
+
+	if node.Size() == 0 && !r.emptyComplete {
+		return events
+	}
+
+	event := newEventByOpNode(WriteComplete, node)
+	event.observedAt = time.Now()
+	event.prev = prev
+
+	if debug {
+		log.Println("[Root/completeWrite] event: " + event.String())
 	}
+
+	// sent after hash computed.
+	if r.checksums.submit(event, r) {
+		return events
+	}
+
+	// send channel
+	return r.emit(events, event)
 }
 
 func (r *Root) checkDirectories() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	defer r.updateStatus()
+
+	start := time.Now()
 
 	eqs, _ := r.root.checkDirectory()
 
 	*(r.queues) = append(*(r.queues), eqs...)
+
+	r.status.update(func(st *Status) {
+		st.LastRescan = start
+		st.RescanDuration = time.Since(start)
+	})
+}
+
+// recreate watcher and nodes from disk.
+func (r *Root) rebuild() error {
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer r.updateStatus()
+
+	start := time.Now()
+
+	fi, sys, err := statInfo(r.root.Path())
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if r.watcher != nil {
+		r.watcher.Close()
+	}
+
+	if r.closeWatcher != nil {
+		r.closeWatcher.close()
+
+		if r.closeWatcher, err = newCloseWatcher(r.closed); err != nil {
+			return err
+		}
+	}
+
+	links := r.links
+	writeNodes := r.writeNodes
+
+	r.root = &Node{
+		dirs:  map[string]*Node{},
+		files: map[string]*Node{},
+	}
+	r.root.setInfo(fi, sys)
+	r.nodeMap = &NodeMap{}
+	r.links = &linkMap{}
+	r.followed = map[devIno]*Node{}
+	r.linkTargets = map[string][]*Node{}
+	r.writeNodes = &NodeMap{}
+	r.queues.clear()
+	r.watcher = watcher
+	r.watched = map[string]bool{}
+	r.nodes = 0
+	r.dirs = 0
+
+	if err := r.addNode(r.root); err != nil {
+		return err
+	}
+
+	if err := r.appendNodes(r.root); err != nil {
+		return err
+	}
+
+	if err := r.followLinks(r.root); err != nil {
+		return err
+	}
+
+	r.keepIDs(links)
+	r.keepWrites(writeNodes)
+
+	r.status.update(func(st *Status) {
+		st.LastRescan = start
+		st.RescanDuration = time.Since(start)
+	})
+
+	return nil
+}
+
+// correlation IDs of nodes before rebuild are kept on same (dev, ino).
+// same path is preferred in hard links.
+func (r *Root) keepIDs(old *linkMap) {
+	if old == nil {
+		return
+	}
+
+	ids := map[devIno][]*Node{}
+	for _, links := range *old {
+		for _, n := range links {
+			key := devIno{dev: n.Dev(), ino: n.Ino()}
+			ids[key] = append(ids[key], n)
+		}
+	}
+
+	kept := map[*Node]bool{}
+
+	// same path first, then other hard links.
+	for _, samePath := range []bool{true, false} {
+		for _, links := range *r.links {
+			for _, n := range links {
+				if kept[n] {
+					continue
+				}
+
+				key := devIno{dev: n.Dev(), ino: n.Ino()}
+				olds := ids[key]
+
+				for i, o := range olds {
+					if samePath && o.Path() != n.Path() {
+						continue
+					}
+
+					n.id = o.id
+					kept[n] = true
+					ids[key] = append(olds[:i], olds[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+// files in writing before rebuild are kept when same file is on same path.
+func (r *Root) keepWrites(old *NodeMap) {
+	for _, o := range *old {
+		n, err := r.Find(o.Path())
+		if err != nil || n.key() != o.key() {
+			continue
+		}
+
+		n.takeWrite(o)
+		r.writeNodes.add(n)
+	}
+}
+
+// SetMaxRestarts sets restart limit of watch loop after panic.
+// 0 is stop watching on first panic.
+// restart count is reset after watch loop runs stable for 10 minutes.
+func (r *Root) SetMaxRestarts(n int) error {
+	if n < 0 {
+		return errors.New("[Root/SetMaxRestarts] error: restarts must be 0 or more.")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxRestarts = n
+
+	return nil
 }
 
 // watch start on goroutine.
@@ -378,20 +848,172 @@ func (r *Root) Watch() {
 		return
 	}
 
+	r.ticker = time.NewTicker(1 * time.Second)
+	r.chkTicker = time.NewTicker(60 * time.Second)
+
+	r.status.update(func(st *Status) {
+		st.Watching = true
+	})
+
+	stopped := make(chan struct{})
+	r.mu.Lock()
+	r.stopped = stopped
+	r.mu.Unlock()
+
 	go func() {
-		r.ticker = time.NewTicker(1 * time.Second)
-		r.chkTicker = time.NewTicker(60 * time.Second)
+		defer close(stopped)
+		defer r.ticker.Stop()
+		defer r.chkTicker.Stop()
+		defer r.status.update(func(st *Status) {
+			st.Watching = false
+		})
 
 		for {
-			select {
-			case e := <-r.watcher.Events:
-				r.addQueue(e)
-			case <-r.ticker.C:
-				r.checkWriteNodes()
-				r.queuesToEvent()
-			case <-r.chkTicker.C:
-				r.checkDirectories()
+			started := time.Now()
+
+			err := r.watchLoop()
+			if err == nil {
+				// watcher closed.
+				return
 			}
+
+			r.sendError(err)
+
+			// limit is for crash loop only.
+			if time.Since(started) >= r.restartReset {
+				r.restarts = 0
+			}
+
+			if !r.restart() {
+				return
+			}
+
+			// total restarts
+			r.status.update(func(st *Status) {
+				st.Restarts++
+			})
 		}
 	}()
 }
+
+// rebuild node tree for restart of watch loop.
+// failed rebuild is counted as restart and retried after restartDelay.
+// false when restart limit is exceeded or Root is closed.
+func (r *Root) restart() bool {
+	for {
+		r.mu.Lock()
+		maxRestarts := r.maxRestarts
+		r.mu.Unlock()
+
+		if r.restarts >= maxRestarts {
+			r.sendError(errors.New(fmt.Sprintf("[Root/Watch] error: restart limit %d exceeded.", maxRestarts)))
+			return false
+		}
+		r.restarts++
+
+		err := r.rebuild()
+		if err == nil {
+			return true
+		}
+
+		r.sendError(err)
+
+		select {
+		case <-r.done:
+			return false
+		case <-time.After(r.restartDelay):
+		}
+	}
+}
+
+// rebuild node tree on watch loop when watching. (addQueue is not called while rebuild)
+// queues are processed before rebuild.
+func (r *Root) requestRebuild() error {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.mu.Unlock()
+
+	if stopped == nil {
+		return r.rebuild()
+	}
+
+	req := make(chan error, 1)
+
+	select {
+	case r.rebuilds <- req:
+		return <-req
+	case <-stopped:
+		return r.rebuild()
+	}
+}
+
+// watcher is replaced on rebuild.
+func (r *Root) currentWatcher() *fsnotify.Watcher {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.watcher
+}
+
+// watcher recreated after w is closed on rebuild.
+// nil when w is closed on Close.
+func (r *Root) rebuiltWatcher(w *fsnotify.Watcher) *fsnotify.Watcher {
+	if next := r.currentWatcher(); next != w {
+		return next
+	}
+
+	return nil
+}
+
+// return error when panic recovered.
+func (r *Root) watchLoop() (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = newPanicError("Root/watchLoop", p)
+		}
+	}()
+
+	watcher := r.currentWatcher()
+
+	for {
+		select {
+		case e, ok := <-watcher.Events:
+			if !ok {
+				// closed on rebuild (e.g. SetSymlinkPolicy)
+				if watcher = r.rebuiltWatcher(watcher); watcher == nil {
+					return nil
+				}
+				continue
+			}
+			r.addQueue(e, time.Now())
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				if watcher = r.rebuiltWatcher(watcher); watcher == nil {
+					return nil
+				}
+				continue
+			}
+
+			// watcher is blocked until error is read.
+			r.status.update(func(st *Status) {
+				st.WatcherErrors++
+			})
+			r.sendError(errors.New(fmt.Sprintf("[Root/watchLoop] watcher error: %s", err)))
+		case p := <-r.closed:
+			r.addClosed(p)
+		case req := <-r.rebuilds:
+			r.queuesToEvent()
+			req <- r.rebuild()
+			watcher = r.currentWatcher()
+		case err := <-r.panics:
+			return err
+		case <-r.ticker.C:
+			r.checkWriteNodes()
+			r.queuesToEvent()
+			r.checkClosed()
+			r.sendBatch()
+		case <-r.chkTicker.C:
+			r.checkDirectories()
+		}
+	}
+}
@@ -273,7 +273,7 @@ func SubTestManipulateDirectory(t *testing.T) {
 		}
 	}
 
-	testNodes(t, _root.node)
+	testNodes(t, _root.root)
 }
 
 func testSamePathName(t *testing.T, node *Node, p, n string) error {
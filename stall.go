@@ -0,0 +1,112 @@
+package dirnotify
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// name of file in downloading
+	partialSuffixes = []string{".part", ".partial", ".crdownload"}
+)
+
+// SetWriteStalled sends WriteStalled of files unchanged for timeout but still incomplete.
+// incomplete is opened by some process (Linux only) or has partial suffix. (e.g. ".part")
+// WriteComplete is not sent while incomplete regardless of timeout.
+// 0 is disabled. (incomplete is not checked)
+func (r *Root) SetWriteStalled(timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.New("[Root/SetWriteStalled] error: timeout must be 0 or more.")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stallTimeout = timeout
+
+	return nil
+}
+
+// checked only when WriteStalled is enabled.
+// called when Root.mu locked.
+func (r *Root) incomplete(p string) bool {
+	if r.stallTimeout == 0 {
+		return false
+	}
+
+	return isPartial(p) || r.opened[p]
+}
+
+// scan opened files in writing once per check, without Root.mu.
+func (r *Root) scanOpened() {
+	r.mu.Lock()
+	if r.stallTimeout == 0 {
+		r.opened = nil
+		r.mu.Unlock()
+		return
+	}
+
+	paths := []string{}
+	for _, node := range *(r.writeNodes) {
+		paths = append(paths, node.Path())
+	}
+	// completed on checkClosed after queues are processed.
+	for p := range r.closes {
+		paths = append(paths, p)
+	}
+	r.mu.Unlock()
+
+	opened := openedPaths(paths)
+
+	r.mu.Lock()
+	r.opened = opened
+	r.mu.Unlock()
+}
+
+func isPartial(p string) bool {
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(p, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// append WriteStalled and remove nodes from writeNodes.
+// tracked again on next write event.
+// called when Root.mu locked.
+func (r *Root) writeStalled(events []Event) []Event {
+	nodes := []*Node{}
+
+	for _, node := range *(r.writeNodes) {
+		if node.stalled {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Path() < nodes[j].Path()
+	})
+
+	for _, node := range nodes {
+		r.writeNodes.remove(node.key())
+
+		event := newEventByOpNode(WriteStalled, node)
+		event.observedAt = time.Now()
+		event.writeElapsed = time.Since(node.firstWrite)
+		event.prev = node.endWrite()
+
+		if debug {
+			log.Println("[Root/writeStalled] event: " + event.String())
+		}
+
+		// send channel
+		events = r.emit(events, event)
+	}
+
+	return events
+}
@@ -0,0 +1,72 @@
+//go:build linux
+// +build linux
+
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// scan /proc/*/fd once for all paths.
+// processes of other users are skipped without permission.
+// returned keys are paths opened by some process.
+func openedPaths(paths []string) map[string]bool {
+	opened := map[string]bool{}
+	if len(paths) == 0 {
+		return opened
+	}
+
+	// link target of fd -> path
+	targets := map[string]string{}
+	for _, p := range paths {
+		targets[filepath.Clean(p)] = p
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			targets[real] = p
+		}
+	}
+
+	procs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return opened
+	}
+
+	for _, proc := range procs {
+		if !proc.IsDir() || !isPid(proc.Name()) {
+			continue
+		}
+
+		dir := filepath.Join("/proc", proc.Name(), "fd")
+
+		f, err := os.Open(dir)
+		if err != nil {
+			continue
+		}
+		fds, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(dir, fd)); err == nil {
+				if p, ok := targets[target]; ok {
+					opened[p] = true
+				}
+			}
+		}
+	}
+
+	return opened
+}
+
+func isPid(name string) bool {
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return name != ""
+}
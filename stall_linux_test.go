@@ -0,0 +1,105 @@
+package dirnotify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsOpened(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "test.txt")
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("[TestIsOpened] failed to create file: %s", err)
+	}
+
+	if !openedPaths([]string{p})[p] {
+		t.Fatalf("[TestIsOpened] opened file is not found.")
+	}
+
+	f.Close()
+
+	if openedPaths([]string{p})[p] {
+		t.Fatalf("[TestIsOpened] closed file is found.")
+	}
+}
+
+func TestIncompleteOpened(t *testing.T) {
+	r, dir := createTestFileTree(t, "upload/data.bin")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{Op: WriteComplete, BufferSize: 1})
+	defer cancel()
+
+	if err := r.SetWriteStalled(time.Hour); err != nil {
+		t.Fatalf("[TestIncompleteOpened] failed to SetWriteStalled: %s", err)
+	}
+
+	p := filepath.Join(dir, "upload", "data.bin")
+
+	// opened by other writer.
+	f, err := os.OpenFile(p, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("[TestIncompleteOpened] failed to open file: %s", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(make([]byte, 1024)); err != nil {
+		t.Fatalf("[TestIncompleteOpened] failed to write file: %s", err)
+	}
+
+	node, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestIncompleteOpened] cannot find node: %s", err)
+	}
+	r.appendWriteNodes(nodeEvent{Op: Write, node: node})
+
+	r.checkWriteNodes()
+	r.addClosed(p)
+	r.checkWriteNodes()
+
+	if len(*(r.writeNodes)) != 1 || len(ch) != 0 {
+		t.Fatalf("[TestIncompleteOpened] opened file is completed.")
+	}
+
+	f.Close()
+	r.checkWriteNodes()
+
+	select {
+	case e := <-ch:
+		if e.Path() != p {
+			t.Fatalf("[TestIncompleteOpened] event is different: %s", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("[TestIncompleteOpened] WriteComplete is not sent after close.")
+	}
+
+	// opened file is completed when WriteStalled is disabled.
+	if err := r.SetWriteStalled(0); err != nil {
+		t.Fatalf("[TestIncompleteOpened] failed to SetWriteStalled: %s", err)
+	}
+
+	if f, err = os.OpenFile(p, os.O_WRONLY, 0644); err != nil {
+		t.Fatalf("[TestIncompleteOpened] failed to open file: %s", err)
+	}
+	defer f.Close()
+
+	r.appendWriteNodes(nodeEvent{Op: Write, node: node})
+	r.addClosed(p)
+	r.checkWriteNodes()
+
+	select {
+	case e := <-ch:
+		if e.Path() != p {
+			t.Fatalf("[TestIncompleteOpened] event is different: %s", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("[TestIncompleteOpened] opened file is not completed without WriteStalled.")
+	}
+}
@@ -0,0 +1,79 @@
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteStalled(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	part := filepath.Join(dir, "test.bin.part")
+	txt := filepath.Join(dir, "test.txt")
+	for _, p := range []string{part, txt} {
+		if err := ioutil.WriteFile(p, []byte("test"), 0644); err != nil {
+			t.Fatalf("[TestWriteStalled] failed to write file: %s", err)
+		}
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestWriteStalled] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	if err := r.SetWriteStalled(-1); err == nil {
+		t.Fatalf("[TestWriteStalled] negative timeout is accepted.")
+	}
+	if err := r.SetWriteStalled(20 * time.Millisecond); err != nil {
+		t.Fatalf("[TestWriteStalled] failed to SetWriteStalled: %s", err)
+	}
+
+	for _, p := range []string{part, txt} {
+		node, err := r.Find(p)
+		if err != nil {
+			t.Fatalf("[TestWriteStalled] cannot find node: %s", err)
+		}
+		r.appendWriteNodes(nodeEvent{Op: Write, node: node})
+	}
+
+	// partial file is not completed.
+	r.checkWriteNodes()
+
+	if len(*(r.writeNodes)) != 1 {
+		t.Fatalf("[TestWriteStalled] partial file is completed.")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	r.checkWriteNodes()
+
+	if len(*(r.writeNodes)) != 0 {
+		t.Fatalf("[TestWriteStalled] stalled file is still tracked.")
+	}
+
+	patterns := []struct {
+		Op
+		path string
+	}{
+		{WriteComplete, txt},
+		{WriteStalled, part},
+	}
+
+	for _, pattern := range patterns {
+		select {
+		case e := <-ch:
+			if e.Op() != pattern.Op || e.Path() != pattern.path {
+				t.Fatalf("[TestWriteStalled] event is different. expect: %s %s, fact: %s", pattern.Op, pattern.path, e)
+			}
+		default:
+			t.Fatalf("[TestWriteStalled] %s of %s is not sent.", pattern.Op, pattern.path)
+		}
+	}
+}
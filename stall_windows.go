@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package dirnotify
+
+// not supported. (partial suffix only)
+func openedPaths(paths []string) map[string]bool {
+	return map[string]bool{}
+}
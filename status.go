@@ -0,0 +1,96 @@
+package dirnotify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type Status struct {
+	Path           string // root directory
+	Nodes          int    // files and directories
+	Dirs           int
+	Watches        int // inotify watch count
+	PendingQueues  int // eventQueues length
+	WriteNodes     int
+	LastEvent      time.Time
+	LastRescan     time.Time
+	RescanDuration time.Duration
+	Errors         uint64
+	WatcherErrors  uint64 // errors from watcher (e.g. queue overflow)
+	ChecksumDrops  uint64 // WriteComplete sent without hash on full queue
+	Restarts       int
+	Watching       bool               // watch loop is running
+	Subscribers    []SubscriberStatus // subscription order
+	DroppedEvents  uint64             // total dropped of Subscribers
+}
+
+type status struct {
+	Status
+	mu sync.Mutex
+}
+
+func (s *status) snapshot() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Status
+}
+
+func (s *status) update(fn func(st *Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.Status)
+}
+
+// Status returns watching state without waiting event delivery.
+func (r *Root) Status() Status {
+	st := r.status.snapshot()
+
+	st.Subscribers = r.subscribers.status()
+	for _, s := range st.Subscribers {
+		st.DroppedEvents += s.Dropped
+	}
+
+	return st
+}
+
+// Healthy returns error when watcher is not alive.
+func (r *Root) Healthy() error {
+	s := r.Status()
+
+	if !s.Watching {
+		return errors.New("[Root/Healthy] error: watch loop is not running.")
+	}
+
+	if s.Watches == 0 {
+		return errors.New("[Root/Healthy] error: no directory is watched.")
+	}
+
+	if _, err := os.Stat(s.Path); err != nil {
+		return errors.New(fmt.Sprintf("[Root/Healthy] error: root directory is unavailable: %s", err))
+	}
+
+	return nil
+}
+
+// called when Root.mu locked.
+func (r *Root) updateStatus() {
+	r.status.update(func(st *Status) {
+		st.Nodes = r.nodes
+		st.Dirs = r.dirs
+		st.Watches = len(r.watched)
+		st.PendingQueues = len(*(r.queues))
+		st.WriteNodes = len(*(r.writeNodes))
+	})
+}
+
+// called when Root.mu locked.
+func (r *Root) updateQueueStatus() {
+	r.status.update(func(st *Status) {
+		st.PendingQueues = len(*(r.queues))
+	})
+}
@@ -0,0 +1,192 @@
+package dirnotify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "usr", "bin"), 0777); err != nil {
+		t.Fatalf("[TestStatus] failed to create directory: %s", err)
+	}
+	if f, err := os.Create(filepath.Join(dir, "usr", "bin", "ls.exe")); err != nil {
+		t.Fatalf("[TestStatus] failed to create file: %s", err)
+	} else {
+		f.Close()
+	}
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestStatus] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	// root, usr, usr/bin, usr/bin/ls.exe
+	s := r.Status()
+	if s.Nodes != 4 || s.Dirs != 3 || s.Watches != 3 {
+		t.Fatalf("[TestStatus] status is different: %+v", s)
+	}
+
+	if err = r.Healthy(); err == nil {
+		t.Fatalf("[TestStatus] healthy before Watch.")
+	}
+
+	r.Watch()
+
+	if err = r.Healthy(); err != nil {
+		t.Fatalf("[TestStatus] unhealthy after Watch: %s", err)
+	}
+}
+
+func TestStatusWatcherErrors(t *testing.T) {
+	dir := tempdir()
+	defer os.RemoveAll(dir)
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestStatusWatcherErrors] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	r.Watch()
+
+	// sent by watcher (e.g. inotify queue overflow)
+	r.currentWatcher().Errors <- errors.New("queue overflow")
+
+	select {
+	case err := <-r.Errors:
+		if err == nil {
+			t.Fatalf("[TestStatusWatcherErrors] error is nil.")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestStatusWatcherErrors] watcher error is not forwarded.")
+	}
+
+	if s := r.Status(); s.WatcherErrors != 1 || s.Errors != 1 {
+		t.Fatalf("[TestStatusWatcherErrors] errors are not counted: %+v", s)
+	}
+
+	if err = r.rebuild(); err != nil {
+		t.Fatalf("[TestStatusWatcherErrors] failed to rebuild: %s", err)
+	}
+
+	if s := r.Status(); s.LastRescan.IsZero() {
+		t.Fatalf("[TestStatusWatcherErrors] LastRescan is not updated on rebuild.")
+	}
+
+	if err = r.Healthy(); err != nil {
+		t.Fatalf("[TestStatusWatcherErrors] unhealthy after rebuild: %s", err)
+	}
+}
+
+func TestStatusHardLinks(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/cat.exe", "opt/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	p := filepath.Join(dir, "usr", "bin", "cat.exe")
+	link := filepath.Join(dir, "usr", "bin", "type.exe")
+	if err := os.Link(p, link); err != nil {
+		t.Fatalf("[TestStatusHardLinks] failed to link: %s", err)
+	}
+
+	r.mu.Lock()
+	node, err := r.createAddNode(link)
+	r.updateStatus()
+	r.mu.Unlock()
+	if err != nil {
+		t.Fatalf("[TestStatusHardLinks] failed to add link: %s", err)
+	}
+
+	// root, usr, usr/bin, cat.exe, type.exe, opt, opt/.keep
+	if s := r.Status(); s.Nodes != 7 || s.Dirs != 4 {
+		t.Fatalf("[TestStatusHardLinks] hard link is not counted: %+v", s)
+	}
+
+	// renamed nodes are not counted again.
+	cat, err := r.Find(p)
+	if err != nil {
+		t.Fatalf("[TestStatusHardLinks] cannot find file: %s", err)
+	}
+	if err := os.Rename(p, filepath.Join(dir, "opt", "cat.exe")); err != nil {
+		t.Fatalf("[TestStatusHardLinks] failed to rename: %s", err)
+	}
+
+	r.mu.Lock()
+	err = r.renameNode(cat, filepath.Join(dir, "opt"), "cat.exe")
+	r.updateStatus()
+	r.mu.Unlock()
+	if err != nil {
+		t.Fatalf("[TestStatusHardLinks] failed to rename node: %s", err)
+	}
+
+	if s := r.Status(); s.Nodes != 7 || s.Dirs != 4 {
+		t.Fatalf("[TestStatusHardLinks] renamed nodes are counted again: %+v", s)
+	}
+
+	r.mu.Lock()
+	err = r.removeNode(node)
+	r.updateStatus()
+	r.mu.Unlock()
+	if err != nil {
+		t.Fatalf("[TestStatusHardLinks] failed to remove link: %s", err)
+	}
+
+	if s := r.Status(); s.Nodes != 6 || s.Dirs != 4 {
+		t.Fatalf("[TestStatusHardLinks] status is different after remove: %+v", s)
+	}
+}
+
+func TestStatusRemoveDirectories(t *testing.T) {
+	r, dir := createTestFileTree(t, "b/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	// events are not read.
+	_, cancel := r.Subscribe(SubscribeOptions{Overflow: DropNewest})
+	defer cancel()
+
+	r.Watch()
+
+	// wait until status is updated on tick.
+	waitStatus := func(name string, nodes, dirs int) {
+		var s Status
+		for i := 0; i < 50; i++ {
+			if s = r.Status(); s.Nodes == nodes && s.Dirs == dirs && s.Watches == dirs {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("[TestStatusRemoveDirectories] status is different after %s: %+v", name, s)
+	}
+
+	// root, b, b/.keep, a, a/c, a/c/d
+	for i, p := range []string{"a", "a/c", "a/c/d"} {
+		if err := os.Mkdir(filepath.Join(dir, filepath.FromSlash(p)), 0777); err != nil {
+			t.Fatalf("[TestStatusRemoveDirectories] failed to create directory: %s", err)
+		}
+		waitStatus("mkdir "+p, 4+i, 3+i)
+	}
+
+	// Remove of a/c and a/c/d
+	if err := os.RemoveAll(filepath.Join(dir, "a", "c")); err != nil {
+		t.Fatalf("[TestStatusRemoveDirectories] failed to remove directory: %s", err)
+	}
+	waitStatus("remove", 4, 3)
+
+	if err := os.Rename(filepath.Join(dir, "a"), filepath.Join(dir, "b", "a")); err != nil {
+		t.Fatalf("[TestStatusRemoveDirectories] failed to move directory: %s", err)
+	}
+	waitStatus("move", 4, 3)
+
+	if err := os.RemoveAll(filepath.Join(dir, "b")); err != nil {
+		t.Fatalf("[TestStatusRemoveDirectories] failed to remove directory: %s", err)
+	}
+	waitStatus("remove moved", 1, 1)
+}
@@ -0,0 +1,298 @@
+package dirnotify
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// behavior when subscriber buffer is full.
+type OverflowPolicy int
+
+const (
+	Block      OverflowPolicy = iota // wait until subscriber receives
+	DropNewest                       // discard sending event
+	DropOldest                       // discard oldest buffered event (BufferSize is 1 at least)
+)
+
+type SubscribeOptions struct {
+	Op         Op // receive events matched Op mask. (0 is all events.)
+	BufferSize int
+	Overflow   OverflowPolicy
+}
+
+type subscriber struct {
+	opts    SubscribeOptions
+	ch      chan Event // nil on batch subscriber
+	batches chan Batch // not nil on batch subscriber
+	done    chan struct{}
+	closing <-chan struct{} // Root is closing (not waited)
+	dropped uint64          // read without s.mu (Status)
+	closed  bool
+	mu      sync.Mutex
+}
+
+func newSubscriber(opts SubscribeOptions) *subscriber {
+	if opts.BufferSize < 0 {
+		opts.BufferSize = 0
+	}
+	// nothing to discard without buffer.
+	if opts.Overflow == DropOldest && opts.BufferSize == 0 {
+		opts.BufferSize = 1
+	}
+
+	return &subscriber{
+		opts: opts,
+		done: make(chan struct{}),
+	}
+}
+
+func (s *subscriber) match(e Event) bool {
+	return s.opts.Op == 0 || e.op&s.opts.Op > 0
+}
+
+func (s *subscriber) deliver(e Event) {
+	if s.batches != nil || !s.match(e) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.overflow(func() bool {
+		select {
+		case s.ch <- e:
+			return true
+		default:
+			return false
+		}
+	}, func() bool {
+		select {
+		case <-s.ch:
+			return true
+		default:
+			return false
+		}
+	}, func() {
+		select {
+		case s.ch <- e:
+		case <-s.done:
+		case <-s.closing:
+		}
+	})
+}
+
+// apply overflow policy. called when s.mu locked.
+// offer: send without blocking, drop: remove oldest buffered, wait: send until closed.
+func (s *subscriber) overflow(offer func() bool, drop func() bool, wait func()) {
+	switch s.opts.Overflow {
+	case DropNewest:
+		if !offer() {
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case DropOldest:
+		// remove oldest and retry until closed.
+		for !offer() {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+
+			if drop() {
+				atomic.AddUint64(&s.dropped, 1)
+			}
+		}
+	default:
+		// buffered event is sent even when closing.
+		if !offer() {
+			wait()
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	// unblock deliver() before lock.
+	close(s.done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.ch != nil {
+		close(s.ch)
+	}
+	if s.batches != nil {
+		close(s.batches)
+	}
+}
+
+type subscribers struct {
+	list   map[uint64]*subscriber
+	lastID uint64
+	mu     sync.Mutex
+}
+
+func newSubscribers() *subscribers {
+	return &subscribers{
+		list: map[uint64]*subscriber{},
+	}
+}
+
+func (ss *subscribers) add(s *subscriber) uint64 {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.lastID++
+	ss.list[ss.lastID] = s
+
+	return ss.lastID
+}
+
+func (ss *subscribers) remove(id uint64) {
+	ss.mu.Lock()
+	s, ok := ss.list[id]
+	delete(ss.list, id)
+	ss.mu.Unlock()
+
+	if ok {
+		s.close()
+	}
+}
+
+func (ss *subscribers) removeAll() {
+	ss.mu.Lock()
+	list := ss.list
+	ss.list = map[uint64]*subscriber{}
+	ss.mu.Unlock()
+
+	for _, s := range list {
+		s.close()
+	}
+}
+
+// SubscriberStatus is state of Subscribe or SubscribeBatches.
+type SubscriberStatus struct {
+	Op       Op
+	Overflow OverflowPolicy
+	Buffered int    // events (or batches) in buffer
+	Dropped  uint64 // discarded by DropNewest or DropOldest
+}
+
+// subscription order. (s.mu is not locked while blocked delivery)
+func (ss *subscribers) status() []SubscriberStatus {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ids := make([]uint64, 0, len(ss.list))
+	for id := range ss.list {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	list := make([]SubscriberStatus, 0, len(ids))
+	for _, id := range ids {
+		s := ss.list[id]
+		st := SubscriberStatus{
+			Op:       s.opts.Op,
+			Overflow: s.opts.Overflow,
+			Dropped:  atomic.LoadUint64(&s.dropped),
+		}
+		if s.batches != nil {
+			st.Buffered = len(s.batches)
+		} else {
+			st.Buffered = len(s.ch)
+		}
+
+		list = append(list, st)
+	}
+
+	return list
+}
+
+// copy for delivering without lock.
+func (ss *subscribers) snapshot() []*subscriber {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	list := make([]*subscriber, 0, len(ss.list))
+	for _, s := range ss.list {
+		list = append(list, s)
+	}
+
+	return list
+}
+
+// Subscribe returns independent event stream.
+// Root.Ch is not sent while one or more subscribers (including SubscribeBatches) or handlers exist.
+// cancel closes returned channel and releases subscriber.
+func (r *Root) Subscribe(opts SubscribeOptions) (<-chan Event, func()) {
+	s := newSubscriber(opts)
+	s.ch = make(chan Event, s.opts.BufferSize)
+
+	return s.ch, r.subscribe(s)
+}
+
+// add subscriber and return cancel function.
+func (r *Root) subscribe(s *subscriber) func() {
+	s.closing = r.done
+	id := r.subscribers.add(s)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.subscribers.remove(id)
+		})
+	}
+}
+
+// send event to handlers, subscribers or Root.Ch.
+// return event with sequence number.
+// called when Root.mu locked.
+func (r *Root) send(e Event) Event {
+	now := time.Now()
+
+	e.seq = atomic.AddUint64(&r.seq, 1)
+	e.deliveredAt = now
+	if e.observedAt.IsZero() {
+		e.observedAt = now
+	}
+
+	r.status.update(func(st *Status) {
+		st.LastEvent = now
+	})
+
+	subs := r.subscribers.snapshot()
+
+	if len(subs) == 0 && r.handlers.len() == 0 {
+		select {
+		case r.Ch <- e:
+		case <-r.done:
+			// sent only to waiting receiver on Close.
+			select {
+			case r.Ch <- e:
+			default:
+			}
+		}
+		return e
+	}
+
+	r.handlers.dispatch(e, r)
+
+	for _, s := range subs {
+		s.deliver(e)
+	}
+
+	return e
+}
@@ -0,0 +1,150 @@
+package dirnotify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	r := newTestEventRoot()
+
+	allCh, cancelAll := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	createCh, cancelCreate := r.Subscribe(SubscribeOptions{Op: Create, BufferSize: 4})
+	dropCh, cancelDrop := r.Subscribe(SubscribeOptions{BufferSize: 1, Overflow: DropOldest})
+
+	events := []Event{
+		Event{op: Create, path: "/tmp/foo"},
+		Event{op: Remove, path: "/tmp/foo"},
+	}
+
+	for _, e := range events {
+		r.send(e)
+	}
+
+	// all events
+	for i, e := range events {
+		got := <-allCh
+		if got.Path() != e.Path() || got.Op() != e.Op() {
+			t.Fatalf("[TestSubscribe] event is different: %s : %s", e, got)
+		}
+
+		if got.Seq() != uint64(i+1) || got.DeliveredAt().IsZero() || got.ObservedAt().IsZero() {
+			t.Fatalf("[TestSubscribe] sequence or timestamp is not set: %d, %s", got.Seq(), got.DeliveredAt())
+		}
+	}
+
+	// filtered events
+	if got := <-createCh; got.Op() != Create {
+		t.Fatalf("[TestSubscribe] filtered event is different: %s", got)
+	}
+	if len(createCh) != 0 {
+		t.Fatalf("[TestSubscribe] filtered subscriber received non target event.")
+	}
+
+	// overflow
+	if got := <-dropCh; got.Op() != Remove {
+		t.Fatalf("[TestSubscribe] DropOldest kept old event: %s", got)
+	}
+
+	// cancel
+	cancelCreate()
+	cancelCreate()
+	if _, ok := <-createCh; ok {
+		t.Fatalf("[TestSubscribe] channel is not closed after cancel.")
+	}
+
+	cancelAll()
+	cancelDrop()
+
+	if len(r.subscribers.snapshot()) != 0 {
+		t.Fatalf("[TestSubscribe] subscribers remain after cancel.")
+	}
+}
+
+func TestSubscribeDropOldestUnbuffered(t *testing.T) {
+	r := newTestEventRoot()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{Overflow: DropOldest})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		r.send(Event{op: Create, path: "/tmp/foo"})
+		r.send(Event{op: Remove, path: "/tmp/foo"})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestSubscribeDropOldestUnbuffered] send is blocked without receiver.")
+	}
+
+	if got := <-ch; got.Op() != Remove {
+		t.Fatalf("[TestSubscribeDropOldestUnbuffered] DropOldest kept old event: %s", got)
+	}
+
+	cancel()
+
+	if _, ok := <-ch; ok {
+		t.Fatalf("[TestSubscribeDropOldestUnbuffered] channel is not closed after cancel.")
+	}
+}
+
+func TestSubscribeDropped(t *testing.T) {
+	r := newTestEventRoot()
+
+	newest, cancel := r.Subscribe(SubscribeOptions{BufferSize: 1, Overflow: DropNewest})
+	defer cancel()
+	_, cancelOldest := r.Subscribe(SubscribeOptions{Op: Create, BufferSize: 1, Overflow: DropOldest})
+	defer cancelOldest()
+
+	for i := 0; i < 3; i++ {
+		r.send(Event{op: Create, path: "/tmp/foo"})
+	}
+
+	st := r.Status()
+	if len(st.Subscribers) != 2 || st.DroppedEvents != 4 {
+		t.Fatalf("[TestSubscribeDropped] status is different: %+v", st)
+	}
+
+	for i, overflow := range []OverflowPolicy{DropNewest, DropOldest} {
+		if s := st.Subscribers[i]; s.Overflow != overflow || s.Dropped != 2 || s.Buffered != 1 {
+			t.Fatalf("[TestSubscribeDropped] subscriber status is different: %+v", s)
+		}
+	}
+
+	<-newest
+	if s := r.Status().Subscribers[0]; s.Buffered != 0 || s.Dropped != 2 {
+		t.Fatalf("[TestSubscribeDropped] subscriber status is different after receive: %+v", s)
+	}
+}
+
+func TestSubscribeOrder(t *testing.T) {
+	r := newTestEventRoot()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{})
+	defer cancel()
+
+	// watch loop and checksum workers send with Root.mu locked.
+	senders := 8
+	count := 1000
+	for i := 0; i < senders; i++ {
+		go func() {
+			for j := 0; j < count; j++ {
+				r.mu.Lock()
+				r.send(Event{op: Create, path: "/tmp/foo"})
+				r.mu.Unlock()
+			}
+		}()
+	}
+
+	seq := uint64(0)
+	for i := 0; i < senders*count; i++ {
+		e := <-ch
+		if e.Seq() != seq+1 {
+			t.Fatalf("[TestSubscribeOrder] sequence is not ordered. expect: %d, fact: %d", seq+1, e.Seq())
+		}
+		seq = e.Seq()
+	}
+}
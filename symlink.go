@@ -0,0 +1,234 @@
+package dirnotify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	// third party
+	"github.com/satom9to5/fileinfo"
+)
+
+type SymlinkPolicy int
+
+// link to target in root is reported through link path with attributes of target.
+// target directory in root is watched on its own path once, so the link is not expanded.
+const (
+	SymlinkNoFollow     SymlinkPolicy = iota // report link itself and its target
+	SymlinkFollowInRoot                      // follow links to target in root
+	SymlinkFollowAll                         // follow links in root and directory links out of root
+)
+
+// symbolic link attributes (not followed)
+type linkInfo struct {
+	fi       os.FileInfo
+	dir      string
+	ino      uint64
+	sys      *sysInfo
+	target   string // link destination (readlink)
+	followed bool   // watched as directory, or target in root
+	inRoot   string // target path in node tree (followed in root)
+}
+
+// visited directory key for loop detection.
+type devIno struct {
+	dev uint64
+	ino uint64
+}
+
+// nil when p is not symbolic link.
+// fi, ino and sys are result of lstatSys.
+func newLinkInfo(p string, fi os.FileInfo, ino uint64, sys *sysInfo) (*linkInfo, error) {
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return nil, nil
+	}
+
+	target, err := os.Readlink(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &linkInfo{
+		fi:     fi,
+		dir:    filepath.Dir(p),
+		ino:    ino,
+		sys:    sys,
+		target: target,
+	}, nil
+}
+
+// device and inode of p. symbolic link is not followed.
+func lstatKey(p string) (devIno, error) {
+	_, ino, sys, err := lstatSys(p)
+
+	return statKey(ino, sys), err
+}
+
+// dev is 0 when sys is unknown.
+func statKey(ino uint64, sys *sysInfo) devIno {
+	key := devIno{ino: ino}
+	if sys != nil {
+		key.dev = sys.dev
+	}
+
+	return key
+}
+
+// SetSymlinkPolicy sets following of symbolic links.
+// file links are followed only when target is in root.
+// node tree is rebuilt when policy is changed. (watching is continued)
+// pending events and files in writing are kept on rebuild.
+func (r *Root) SetSymlinkPolicy(p SymlinkPolicy) error {
+	if p < SymlinkNoFollow || p > SymlinkFollowAll {
+		return errors.New("[Root/SetSymlinkPolicy] error: unknown policy.")
+	}
+
+	r.mu.Lock()
+	changed := r.symlinkPolicy != p
+	r.symlinkPolicy = p
+	r.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	return r.requestRebuild()
+}
+
+// follow symbolic links under n after real directories are added.
+// real directory is preferred when link has same target.
+func (r *Root) followLinks(n *Node) error {
+	links := []*Node{}
+	for _, file := range n.files {
+		if file.link != nil {
+			links = append(links, file)
+		}
+	}
+
+	for _, link := range links {
+		if err := r.followLink(link); err != nil {
+			return err
+		}
+
+		if !link.IsDir() {
+			continue
+		}
+
+		if err := r.appendNodes(link); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range n.dirs {
+		if err := r.followLinks(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// watch link target as directory when policy allows.
+// link to target in root is not watched. (see followInRoot)
+// children are not appended.
+func (r *Root) followLink(n *Node) error {
+	if n.link == nil || n.link.followed || r.symlinkPolicy == SymlinkNoFollow {
+		return nil
+	}
+
+	// dangling link is not followed.
+	target, err := filepath.EvalSymlinks(n.Path())
+	if err != nil {
+		return nil
+	}
+
+	fi, sys, err := statInfo(n.Path())
+	if err != nil {
+		return nil
+	}
+
+	if p, ok := r.inRoot(target); ok {
+		r.followInRoot(n, p, fi, sys)
+		return nil
+	}
+
+	// loop or already watched directory
+	if r.symlinkPolicy != SymlinkFollowAll || !fi.IsDir() || sys == nil || r.visited(devIno{dev: sys.dev, ino: fi.Ino()}) {
+		return nil
+	}
+
+	n.link.followed = true
+	n.setInfo(fi, sys)
+	n.dirs = map[string]*Node{}
+	n.files = map[string]*Node{}
+
+	// move to parent dirs
+	delete(n.parent.files, n.Name())
+	n.parent.dirs[n.Name()] = n
+
+	r.followed[n.dirKey()] = n
+
+	return r.addWatch(n)
+}
+
+// link node has attributes of target, kept in parent files.
+// changes of target are reported through link path too. (see targetLinks)
+func (r *Root) followInRoot(n *Node, p string, fi *fileinfo.FileInfo, sys *sysInfo) {
+	n.link.followed = true
+	n.link.inRoot = p
+	n.setInfo(fi, sys)
+
+	r.linkTargets[p] = append(r.linkTargets[p], n)
+}
+
+// target path in node tree when resolved target is in root.
+func (r *Root) inRoot(target string) (string, bool) {
+	root, err := filepath.EvalSymlinks(r.root.Path())
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+fileinfo.PathSep) {
+		return "", false
+	}
+
+	return filepath.Join(r.root.Path(), rel), true
+}
+
+// followed links to p which are in node tree.
+func (r *Root) targetLinks(p string) []*Node {
+	links := []*Node{}
+
+	for _, link := range r.linkTargets[p] {
+		if link.link == nil || link.link.inRoot != p {
+			continue
+		}
+		if node, err := r.Find(link.Path()); err == nil && node == link {
+			links = append(links, link)
+		}
+	}
+
+	if len(links) == 0 {
+		delete(r.linkTargets, p)
+	} else {
+		r.linkTargets[p] = links
+	}
+
+	return links
+}
+
+// check directory is already in node tree.
+func (r *Root) visited(key devIno) bool {
+	if _, ok := r.followed[key]; ok {
+		return true
+	}
+
+	for _, node := range r.links.get(key) {
+		if node.IsDir() && node.dirKey() == key {
+			return true
+		}
+	}
+
+	return false
+}
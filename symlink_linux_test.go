@@ -0,0 +1,368 @@
+package dirnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	// third party
+	"github.com/satom9to5/fsnotify"
+)
+
+func createTestLinks(t *testing.T, dir string, links map[string]string) {
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("[createTestLinks] failed to create symlink: %s", err)
+		}
+	}
+}
+
+func TestSymlinkNoFollow(t *testing.T) {
+	ext := tempdir()
+	defer os.RemoveAll(ext)
+
+	r, dir := createTestFileTree(t, "usr/lib/libc.so.6")
+	defer os.RemoveAll(dir)
+	r.Close()
+
+	createTestLinks(t, dir, map[string]string{
+		"usr/lib/libc.so": "libc.so.6",
+		"usr/ext":         ext,
+		"usr/loop":        "..",
+		"usr/dangling":    "nowhere",
+	})
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestSymlinkNoFollow] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	patterns := []struct {
+		name   string
+		target string
+	}{
+		{"usr/lib/libc.so", "libc.so.6"},
+		{"usr/ext", ext},
+		{"usr/loop", ".."},
+		{"usr/dangling", "nowhere"},
+	}
+
+	for _, pattern := range patterns {
+		p := filepath.Join(dir, filepath.FromSlash(pattern.name))
+
+		node, err := r.Find(p)
+		if err != nil {
+			t.Fatalf("[TestSymlinkNoFollow] failed to Root/Find: %s", err)
+		}
+
+		key, _ := lstatKey(p)
+		if node.IsDir() || node.LinkTarget() != pattern.target || node.key() != key || node.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("[TestSymlinkNoFollow] link node is different: %s", node)
+		}
+
+		if e := newEventByOpNode(Create, node); e.LinkTarget() != pattern.target || e.Path() != p {
+			t.Fatalf("[TestSymlinkNoFollow] event is different: %s", e)
+		}
+	}
+}
+
+func TestSymlinkFollow(t *testing.T) {
+	ext := tempdir()
+	defer os.RemoveAll(ext)
+
+	if err := os.Mkdir(filepath.Join(ext, "data"), 0777); err != nil {
+		t.Fatalf("[TestSymlinkFollow] failed to create directory: %s", err)
+	}
+
+	r, dir := createTestFileTree(t, "usr/bin/ls.exe")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	createTestLinks(t, dir, map[string]string{
+		"usr/ext":     ext,
+		"usr/bin/ext": ext,
+		"usr/loop":    "..",
+		"usr/bin2":    "bin",
+		"usr/ls":      "bin/ls.exe",
+	})
+	createTestLinks(t, ext, map[string]string{
+		"data/back": ext,
+	})
+
+	patterns := []struct {
+		SymlinkPolicy
+		followed []string // directories out of root
+		inRoot   []string
+	}{
+		{SymlinkNoFollow, []string{}, []string{}},
+		{SymlinkFollowInRoot, []string{}, []string{"usr/loop", "usr/bin2", "usr/ls"}},
+		{SymlinkFollowAll, []string{"usr/ext"}, []string{"usr/loop", "usr/bin2", "usr/ls"}},
+	}
+
+	for _, pattern := range patterns {
+		if err := r.SetSymlinkPolicy(pattern.SymlinkPolicy); err != nil {
+			t.Fatalf("[TestSymlinkFollow] failed to SetSymlinkPolicy: %s", err)
+		}
+
+		followed, inRoot := 0, 0
+		for _, node := range r.root.children() {
+			if node.link == nil || !node.link.followed {
+				continue
+			}
+
+			if node.link.inRoot != "" {
+				inRoot++
+			} else {
+				followed++
+			}
+		}
+
+		if followed != len(pattern.followed) || inRoot != len(pattern.inRoot) {
+			t.Fatalf("[TestSymlinkFollow] followed links are different. policy: %d, expect: %d/%d, fact: %d/%d", pattern.SymlinkPolicy, len(pattern.followed), len(pattern.inRoot), followed, inRoot)
+		}
+
+		for _, name := range pattern.followed {
+			node, err := r.Find(filepath.Join(dir, filepath.FromSlash(name), "data"))
+			if err != nil || !node.IsDir() {
+				t.Fatalf("[TestSymlinkFollow] followed directory is not found: %v", err)
+			}
+		}
+
+		// reported through link path with attributes of target, not expanded.
+		for _, name := range pattern.inRoot {
+			node, err := r.Find(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil || node.IsDir() || len(node.dirs) != 0 {
+				t.Fatalf("[TestSymlinkFollow] link in root is expanded: %v", err)
+			}
+
+			e := newEventByOpNode(Create, node)
+			if e.Path() != node.Path() || e.LinkTarget() == "" || e.IsDir() != (name != "usr/ls") || e.Mode()&os.ModeSymlink != 0 {
+				t.Fatalf("[TestSymlinkFollow] event of link in root is different: %s", e)
+			}
+		}
+	}
+
+	if err := r.SetSymlinkPolicy(SymlinkPolicy(-1)); err == nil {
+		t.Fatalf("[TestSymlinkFollow] unknown policy is accepted.")
+	}
+}
+
+func TestSymlinkFollowInRoot(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/.keep")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	if err := r.SetSymlinkPolicy(SymlinkFollowInRoot); err != nil {
+		t.Fatalf("[TestSymlinkFollowInRoot] failed to SetSymlinkPolicy: %s", err)
+	}
+
+	ch, cancel := r.Subscribe(SubscribeOptions{Op: WriteComplete, BufferSize: 4})
+	defer cancel()
+
+	r.Watch()
+
+	p := filepath.Join(dir, "usr", "bin", "ls.exe")
+	link := filepath.Join(dir, "usr", "ls")
+
+	if err := ioutil.WriteFile(p, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("[TestSymlinkFollowInRoot] failed to write file: %s", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	createTestLinks(t, dir, map[string]string{
+		"usr/ls": "bin/ls.exe",
+	})
+	time.Sleep(1500 * time.Millisecond)
+
+	// drain WriteComplete of created file
+	for len(ch) > 0 {
+		<-ch
+	}
+
+	if err := ioutil.WriteFile(p, make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("[TestSymlinkFollowInRoot] failed to write file: %s", err)
+	}
+
+	// target and link
+	paths := map[string]bool{}
+	for len(paths) < 2 {
+		select {
+		case e := <-ch:
+			if e.Size() != 1024 {
+				t.Fatalf("[TestSymlinkFollowInRoot] event is different: %s", e)
+			}
+			paths[e.Path()] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("[TestSymlinkFollowInRoot] WriteComplete is not sent on link: %v", paths)
+		}
+	}
+
+	if !paths[p] || !paths[link] {
+		t.Fatalf("[TestSymlinkFollowInRoot] WriteComplete paths are different: %v", paths)
+	}
+}
+
+func TestSymlinkPolicyWatching(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/ls.exe")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{BufferSize: 4})
+	defer cancel()
+
+	r.Watch()
+
+	if err := r.SetSymlinkPolicy(SymlinkFollowAll); err != nil {
+		t.Fatalf("[TestSymlinkPolicyWatching] failed to SetSymlinkPolicy: %s", err)
+	}
+
+	p := filepath.Join(dir, "usr", "bin", "cat.exe")
+	if f, err := os.Create(p); err != nil {
+		t.Fatalf("[TestSymlinkPolicyWatching] failed to create file: %s", err)
+	} else {
+		f.Close()
+	}
+
+	// watch loop continues with rebuilt watcher.
+	select {
+	case e := <-ch:
+		if e.Op() != Create || e.Path() != p {
+			t.Fatalf("[TestSymlinkPolicyWatching] event is different: %s", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[TestSymlinkPolicyWatching] event is not sent after rebuild.")
+	}
+
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("[TestSymlinkPolicyWatching] watcher is not healthy: %s", err)
+	}
+}
+
+func TestSymlinkPolicyPending(t *testing.T) {
+	r, dir := createTestFileTree(t, "usr/bin/ls.exe")
+	defer os.RemoveAll(dir)
+	defer r.Close()
+
+	ch, cancel := r.Subscribe(SubscribeOptions{Op: Create | WriteComplete, BufferSize: 4})
+	defer cancel()
+
+	r.Watch()
+
+	// queued until next tick
+	p := filepath.Join(dir, "usr", "bin", "cat.exe")
+	if err := ioutil.WriteFile(p, make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("[TestSymlinkPolicyPending] failed to write file: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := r.SetSymlinkPolicy(SymlinkFollowAll); err != nil {
+		t.Fatalf("[TestSymlinkPolicyPending] failed to SetSymlinkPolicy: %s", err)
+	}
+
+	for _, op := range []Op{Create, WriteComplete} {
+		select {
+		case e := <-ch:
+			if e.Op() != op || e.Path() != p {
+				t.Fatalf("[TestSymlinkPolicyPending] event is different. expect: %s, fact: %s", op, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("[TestSymlinkPolicyPending] %s is lost on rebuild.", op)
+		}
+	}
+}
+
+func TestSymlinkRelink(t *testing.T) {
+	r, dir := createTestFileTree(t, "app/v1/app.exe", "app/v2/app.exe")
+	defer os.RemoveAll(dir)
+	r.Close()
+
+	current := filepath.Join(dir, "app", "current")
+	next := filepath.Join(dir, "app", "next")
+
+	createTestLinks(t, dir, map[string]string{
+		"app/current": "v1",
+		"app/next":    "v2",
+	})
+
+	r, err := CreateNodeTree([]string{dir})
+	if err != nil {
+		t.Fatalf("[TestSymlinkRelink] cannot create Root: %s", err)
+	}
+	defer r.Close()
+
+	// rename over
+	if err := os.Rename(next, current); err != nil {
+		t.Fatalf("[TestSymlinkRelink] failed to rename: %s", err)
+	}
+
+	eqs := &eventQueues{}
+	eqs.add(fsnotify.Event{Name: next, Op: fsnotify.Rename}, r)
+	eqs.add(fsnotify.Event{Name: current, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	nes, err := eqs.createNodeEvents(r)
+	if err != nil || len(*nes) != 1 {
+		t.Fatalf("[TestSymlinkRelink] failed to createNodeEvents: %v", err)
+	}
+
+	if e := newEvent((*nes)[0]); e.Op() != Relink || e.Path() != current || e.LinkTarget() != "v2" || e.PrevLinkTarget() != "v1" {
+		t.Fatalf("[TestSymlinkRelink] event is different: %s, prev target: %s", e, e.PrevLinkTarget())
+	}
+
+	// remove and create
+	// old inode is kept by link out of root not to be reused.
+	ext := tempdir()
+	defer os.RemoveAll(ext)
+
+	if err := os.Link(current, filepath.Join(ext, "current")); err != nil {
+		t.Fatalf("[TestSymlinkRelink] failed to link: %s", err)
+	}
+	if err := os.Remove(current); err != nil {
+		t.Fatalf("[TestSymlinkRelink] failed to remove: %s", err)
+	}
+	createTestLinks(t, dir, map[string]string{
+		"app/current": "v1",
+	})
+
+	eqs.add(fsnotify.Event{Name: current, Op: fsnotify.Remove}, r)
+	eqs.add(fsnotify.Event{Name: current, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	if nes, err = eqs.createNodeEvents(r); err != nil || len(*nes) != 1 {
+		t.Fatalf("[TestSymlinkRelink] failed to createNodeEvents: %v", err)
+	}
+
+	if e := newEvent((*nes)[0]); e.Op() != Relink || e.LinkTarget() != "v1" || e.PrevLinkTarget() != "v2" {
+		t.Fatalf("[TestSymlinkRelink] event is different: %s, prev target: %s", e, e.PrevLinkTarget())
+	}
+
+	if node, err := r.Find(current); err != nil || node.LinkTarget() != "v1" {
+		t.Fatalf("[TestSymlinkRelink] failed to Root/Find relinked path: %v", err)
+	}
+
+	// remove and create with same inode (e.g. inode reused)
+	tmp := filepath.Join(dir, "current.tmp")
+	for _, fn := range []func() error{
+		func() error { return os.Link(current, tmp) },
+		func() error { return os.Remove(current) },
+		func() error { return os.Link(tmp, current) },
+		func() error { return os.Remove(tmp) },
+	} {
+		if err := fn(); err != nil {
+			t.Fatalf("[TestSymlinkRelink] failed to relink: %s", err)
+		}
+	}
+
+	eqs.add(fsnotify.Event{Name: current, Op: fsnotify.Remove}, r)
+	eqs.add(fsnotify.Event{Name: current, Op: fsnotify.Create}, r)
+	eqs.sort()
+
+	if nes, err = eqs.createNodeEvents(r); err != nil || len(*nes) != 1 {
+		t.Fatalf("[TestSymlinkRelink] failed to createNodeEvents: %v", err)
+	}
+
+	if e := newEvent((*nes)[0]); e.Op() != Relink || e.Path() != current || e.BeforePath() != "" {
+		t.Fatalf("[TestSymlinkRelink] event is different: %s", e)
+	}
+}
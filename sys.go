@@ -0,0 +1,58 @@
+package dirnotify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	// third party
+	"github.com/satom9to5/fileinfo"
+)
+
+// retry count of stat when file is replaced between stats.
+const statRetries = 3
+
+// system dependent attributes not included in fileinfo.FileInfo.
+type sysInfo struct {
+	dev   uint64
+	uid   uint32
+	gid   uint32
+	nlink uint64
+	ctime time.Time
+}
+
+// lstatSys or statSys
+type statFunc func(p string) (os.FileInfo, uint64, *sysInfo, error)
+
+// stat p following symbolic link.
+func statInfo(p string) (*fileinfo.FileInfo, *sysInfo, error) {
+	_, ino, sys, err := statSys(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sameStatInfo(p, statSys, ino, sys)
+}
+
+// fileinfo of file which inode is ino.
+// sys is read by stat before fileinfo, so both are read again when p is replaced between them.
+func sameStatInfo(p string, stat statFunc, ino uint64, sys *sysInfo) (*fileinfo.FileInfo, *sysInfo, error) {
+	for i := 0; ; i++ {
+		fi, err := fileinfo.Stat(p)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if fi.Ino() == ino {
+			return fi, sys, nil
+		}
+
+		if i == statRetries {
+			return nil, nil, errors.New(fmt.Sprintf("[sameStatInfo] error: %s is replaced while stat.", p))
+		}
+
+		if _, ino, sys, err = stat(p); err != nil {
+			return nil, nil, err
+		}
+	}
+}
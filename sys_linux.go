@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package dirnotify
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Remove of inotify is unlink. (moved file is sent as Rename)
+const removeIsUnlink = true
+
+// stat following symbolic link.
+func statSys(p string) (os.FileInfo, uint64, *sysInfo, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi, 0, nil, nil
+	}
+
+	return fi, st.Ino, statSysInfo(st), nil
+}
+
+// stat without following symbolic link.
+func lstatSys(p string) (os.FileInfo, uint64, *sysInfo, error) {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi, 0, nil, nil
+	}
+
+	return fi, st.Ino, statSysInfo(st), nil
+}
+
+func statSysInfo(st *syscall.Stat_t) *sysInfo {
+	return &sysInfo{
+		dev:   uint64(st.Dev),
+		uid:   st.Uid,
+		gid:   st.Gid,
+		nlink: uint64(st.Nlink),
+		ctime: time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec)),
+	}
+}
@@ -0,0 +1,71 @@
+//go:build windows
+// +build windows
+
+package dirnotify
+
+import (
+	"os"
+	"syscall"
+)
+
+// file moved to other directory is sent as Remove and Create.
+const removeIsUnlink = false
+
+// stat following symbolic link.
+func statSys(p string) (os.FileInfo, uint64, *sysInfo, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	hfi, err := fileInformation(p, 0)
+	if err != nil {
+		return fi, 0, nil, nil
+	}
+
+	return fi, uint64(hfi.FileIndexHigh)<<32 | uint64(hfi.FileIndexLow), handleSysInfo(hfi), nil
+}
+
+// stat without following symbolic link.
+func lstatSys(p string) (os.FileInfo, uint64, *sysInfo, error) {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	hfi, err := fileInformation(p, syscall.FILE_FLAG_OPEN_REPARSE_POINT)
+	if err != nil {
+		return fi, 0, nil, nil
+	}
+
+	return fi, uint64(hfi.FileIndexHigh)<<32 | uint64(hfi.FileIndexLow), handleSysInfo(hfi), nil
+}
+
+func fileInformation(p string, flags uint32) (*syscall.ByHandleFileInformation, error) {
+	h, err := syscall.CreateFile(syscall.StringToUTF16Ptr(p),
+		syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED|flags, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	defer syscall.CloseHandle(h)
+
+	var hfi syscall.ByHandleFileInformation
+	if err = syscall.GetFileInformationByHandle(h, &hfi); err != nil {
+		return nil, err
+	}
+
+	return &hfi, nil
+}
+
+// uid/gid is not supported.
+// ctime is zero because change time is not included. (CreationTime is other meaning)
+func handleSysInfo(hfi *syscall.ByHandleFileInformation) *sysInfo {
+	return &sysInfo{
+		dev:   uint64(hfi.VolumeSerialNumber),
+		nlink: uint64(hfi.NumberOfLinks),
+	}
+}